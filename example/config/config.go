@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/qumogu/go-tools/mongodb"
 )
@@ -29,7 +30,13 @@ func Parse() error {
 	Conf.Mongo.Database = getEnvValue("MONGODB_DATABASE", "bruce-test")
 	Conf.Mongo.UserName = getEnvValue("MONGODB_USER_NAME", "admin")
 	Conf.Mongo.PWD = getEnvValue("MONGODB_PWD", "JXdbpd37Q2Ayo") // JXdbpd37Q2Ayo
-	Conf.Mongo.URI = fmt.Sprintf("mongodb://%s:%s@%s", Conf.Mongo.UserName, Conf.Mongo.PWD, Conf.Mongo.Addr)
+	Conf.Mongo.AuthSource = getEnvValue("MONGODB_AUTH_SOURCE", "")
+	Conf.Mongo.ReplicaSet = getEnvValue("MONGODB_REPLICA_SET", "")
+	Conf.Mongo.ReadPreference = getEnvValue("MONGODB_READ_PREFERENCE", "")
+	Conf.Mongo.AppName = getEnvValue("MONGODB_APP_NAME", "go-tools-example")
+	Conf.Mongo.ServerSelectionTimeout = time.Duration(getEnvInt("MONGODB_SERVER_SELECTION_TIMEOUT", 5)) * time.Second
+	// URI 由 MongoConf.GetURI 拼接, 用户名与密码会被转义
+	Conf.Mongo.URI = Conf.Mongo.GetURI()
 	mongodb.InitMongoManager(Conf.Mongo)
 
 	return nil
@@ -41,6 +41,13 @@ type Base struct {
 	UpdatedUserId string `json:"updatedUserId" bson:"updated_user_id"` // 修改人ID
 	UpdatedUser   string `json:"updatedUser" bson:"updated_user"`      // 修改人姓名
 	UpdatedTime   int64  `json:"updatedTime" bson:"updated_time"`      // 修改时间
+
+	// 假删除
+	IsDelete    bool   `json:"-" bson:"is_delete"`
+	DeletedUser string `json:"-" bson:"deleted_user,omitempty"` // 删除人姓名
+	DeletedTime int64  `json:"-" bson:"deleted_time,omitempty"` // 删除时间
+
+	Version int64 `json:"version" bson:"version"` // 版本号, 用于乐观锁
 }
 
 func (b *Base) SetUpdate(userInfo UserInfo) {
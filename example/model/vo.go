@@ -8,11 +8,15 @@ type ConfigurationSearch struct {
 	SignalType       *string  `json:"signal_type" form:"signal_type" bson:"signal_type"`                      // 信号类型, 遥控 1遥信 2遥测
 	PointPositionIDs []string `json:"point_position_ids" form:"point_position_ids" bson:"point_position_ids"` // 点位ID 数组
 	Priority         *int     `json:"priority" form:"priority" bson:"priority"`                               // 优先级
+	DeviceNameLike   *string  `json:"device_name_like" form:"device_name_like" filter:"device_name,op=regex"` // 设备名称包含
+	CreatedFrom      *int64   `json:"created_from" form:"created_from" filter:"created_time,op=gte"`          // 创建时间起
+	CreatedTo        *int64   `json:"created_to" form:"created_to" filter:"created_time,op=lte"`              // 创建时间止
 	Limit            *int     `json:"limit" form:"limit" bson:"limit"`
 	Offset           *int     `json:"offset" form:"offset" bson:"offset"`
 }
 
 type MessageUpdate struct {
-	Title   *string `json:"title" form:"title" bson:"title"`       // 序号
-	Content *string `json:"content" form:"content" bson:"content"` // 设备名称
+	Title   *string `json:"title" form:"title" bson:"title"`                 // 序号
+	Content *string `json:"content" form:"content" bson:"content"`           // 设备名称
+	Version *int64  `json:"version" form:"version" bson:"version,omitempty"` // 期望的版本号, 也可以使用 If-Match 请求头
 }
@@ -11,7 +11,15 @@ import (
 func InitRouter(r *gin.Engine) {
 	msgGrp := r.Group("/api/v1/message")
 	msgGrp.Use(UserInfo)
-	msg := mongodb.NewCrud(config.Conf.Mongo.Database, "message", model.Message{})
+	msg := mongodb.NewCrud(config.Conf.Mongo.Database, "message", model.Message{},
+		mongodb.CrudOptionSoftDelete(model.CTX_USER_NAME),
+		mongodb.CrudOptionTenantScope(model.CTX_ORG_ID),
+		mongodb.CrudOptionVersion(),
+		mongodb.CrudOptionSort(mongodb.SortConfig{
+			Fields:  []string{"created_time", "updated_time", "title"},
+			Default: "-created_time",
+		}),
+	)
 	mongodb.CRUD(msgGrp, "", msg)
 }
 
@@ -1,6 +1,6 @@
 module github.com/qumogu/go-tools
 
-go 1.17
+go 1.18
 
 require (
 	github.com/gin-contrib/cors v1.4.0
@@ -8,6 +8,7 @@ require (
 	github.com/gin-contrib/pprof v1.4.0
 	github.com/gin-contrib/requestid v0.0.6
 	github.com/gin-gonic/gin v1.8.2
+	github.com/klauspost/compress v1.13.6
 	github.com/rabbitmq/amqp091-go v1.7.0
 	github.com/xuri/excelize/v2 v2.7.0
 	go.mongodb.org/mongo-driver v1.11.1
@@ -26,7 +27,6 @@ require (
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
-	github.com/klauspost/compress v1.13.6 // indirect
 	github.com/leodido/go-urn v1.2.1 // indirect
 	github.com/mattn/go-isatty v0.0.16 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
@@ -11,6 +11,7 @@ import (
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
+	"github.com/qumogu/go-tools/trace"
 )
 
 func NewRouter(serviceRunMode string, profile bool) *gin.Engine {
@@ -23,6 +24,7 @@ func NewRouter(serviceRunMode string, profile bool) *gin.Engine {
 		cors(),
 		gin.Recovery(),
 		requestid.New(),
+		traceContext(),
 		gingzip.Gzip(gingzip.DefaultCompression),
 	)
 
@@ -72,6 +74,20 @@ func cors() gin.HandlerFunc {
 	return gincors.New(config)
 }
 
+// traceContext 将请求ID与 traceparent 写入 c.Request.Context(),
+// 使用该 ctx 发布的消息会携带这些信息, 日志使用 logger.WithContext(ctx).
+func traceContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		md := trace.Metadata{
+			trace.HeaderRequestID:   requestid.Get(c),
+			trace.HeaderTraceparent: c.GetHeader(trace.HeaderTraceparent),
+		}
+		c.Request = c.Request.WithContext(trace.NewContext(c.Request.Context(), md))
+
+		c.Next()
+	}
+}
+
 func customLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
@@ -0,0 +1,17 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/qumogu/go-tools/trace"
+)
+
+// WithContext 返回带有 ctx 中请求ID等关联信息的日志.
+func WithContext(ctx context.Context) *Logger {
+	fields := trace.FromContext(ctx).LogFields()
+	if len(fields) == 0 {
+		return DefaultLogger
+	}
+
+	return DefaultLogger.With(fields...)
+}
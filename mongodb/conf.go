@@ -0,0 +1,263 @@
+package mongodb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+const (
+	defaultMaxTenants = 1000
+
+	redactedPassword = "******"
+)
+
+// MongoConf mongo 连接配置, 零值的选项使用 URI 中的设置或 driver 的默认值.
+// URI 为空时由 Addr、UserName、PWD、AuthSource、ReplicaSet 拼接.
+type MongoConf struct {
+	URI      string `json:"-"`                          // mongo server uri
+	Addr     string `json:"addr" yaml:"ADDR"`           // ip:port, 多个节点以逗号分隔
+	Database string `json:"-"`                          // 数据库名称
+	UserName string `json:"user_name" yaml:"USER_NAME"` // 用户名
+	PWD      string `json:"pwd" yaml:"PWD"`             // 密码
+
+	AuthSource string `json:"auth_source" yaml:"AUTH_SOURCE"` // 认证数据库, 默认 admin
+	ReplicaSet string `json:"replica_set" yaml:"REPLICA_SET"` // 副本集名称
+	AppName    string `json:"app_name" yaml:"APP_NAME"`       // 客户端名称, 显示在服务端日志与 currentOp 中
+
+	TLS                bool   `json:"tls" yaml:"TLS"`                                   // 使用 TLS 连接
+	CACertFile         string `json:"ca_cert_file" yaml:"CA_CERT_FILE"`                 // CA 证书文件, 为空时使用系统根证书
+	CertFile           string `json:"cert_file" yaml:"CERT_FILE"`                       // 客户端证书文件, 双向认证时使用
+	KeyFile            string `json:"key_file" yaml:"KEY_FILE"`                         // 客户端私钥文件, 为空时从 CertFile 中读取
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"INSECURE_SKIP_VERIFY"` // 不校验服务端证书, 仅用于测试环境
+
+	MaxPoolSize     uint64        `json:"max_pool_size" yaml:"MAX_POOL_SIZE"`           // 每个集群连接池的最大连接数, 为0时使用 URI 中的 maxPoolSize, driver 默认100
+	MinPoolSize     uint64        `json:"min_pool_size" yaml:"MIN_POOL_SIZE"`           // 每个集群连接池保持的最小连接数
+	MaxConnIdleTime time.Duration `json:"max_conn_idle_time" yaml:"MAX_CONN_IDLE_TIME"` // 空闲连接的最长保留时间, 为0时使用 URI 中的 maxIdleTimeMS
+	MaxTenants      int           `json:"max_tenants" yaml:"MAX_TENANTS"`               // 缓存的租户数据库数, 超过时淘汰最久未使用的, 默认1000
+
+	ConnectTimeout         time.Duration `json:"connect_timeout" yaml:"CONNECT_TIMEOUT"`                   // 建立连接的超时时间
+	SocketTimeout          time.Duration `json:"socket_timeout" yaml:"SOCKET_TIMEOUT"`                     // 读写 socket 的超时时间
+	ServerSelectionTimeout time.Duration `json:"server_selection_timeout" yaml:"SERVER_SELECTION_TIMEOUT"` // 选择可用节点的超时时间
+
+	ReadPreference string `json:"read_preference" yaml:"READ_PREFERENCE"` // primary、primaryPreferred、secondary、secondaryPreferred、nearest
+	WriteConcern   string `json:"write_concern" yaml:"WRITE_CONCERN"`     // majority 或需要确认的节点数
+	RetryWrites    *bool  `json:"retry_writes" yaml:"RETRY_WRITES"`       // 是否重试写操作, 为空时使用 driver 默认值(true)
+}
+
+// GetURI 返回连接地址, URI 为空时拼接地址, 用户名与密码会被转义.
+func (c MongoConf) GetURI() string {
+	if c.URI != "" {
+		return c.URI
+	}
+
+	u := url.URL{
+		Scheme: "mongodb",
+		Host:   c.Addr,
+		Path:   "/",
+	}
+	if c.UserName != "" {
+		u.User = url.UserPassword(c.UserName, c.PWD)
+	}
+
+	query := url.Values{}
+	if c.AuthSource != "" {
+		query.Set("authSource", c.AuthSource)
+	}
+
+	if c.ReplicaSet != "" {
+		query.Set("replicaSet", c.ReplicaSet)
+	}
+
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// String 返回隐藏了密码的配置, 打印配置时使用.
+func (c MongoConf) String() string {
+	// 转换为没有 String 方法的类型, 避免递归调用
+	type conf MongoConf
+
+	return fmt.Sprintf("%+v", conf(c.redacted()))
+}
+
+// GoString 与 String 相同, 避免 %#v 打印出密码.
+func (c MongoConf) GoString() string {
+	return c.String()
+}
+
+// MarshalJSON 输出配置时隐藏密码, 反序列化仍读取 pwd.
+func (c MongoConf) MarshalJSON() ([]byte, error) {
+	// 转换为没有 MarshalJSON 方法的类型, 避免递归调用
+	type conf MongoConf
+
+	return json.Marshal(conf(c.redacted()))
+}
+
+// redacted 返回隐藏了密码的副本.
+func (c MongoConf) redacted() MongoConf {
+	redacted := c
+	redacted.URI = RedactURI(c.URI)
+	if c.PWD != "" {
+		redacted.PWD = redactedPassword
+	}
+
+	return redacted
+}
+
+// clientOptions 生成连接 uri 对应集群的 client 配置.
+func (c MongoConf) clientOptions(uri string) (*options.ClientOptions, error) {
+	opts := options.Client().ApplyURI(uri)
+
+	if c.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(c.MaxPoolSize)
+	}
+
+	if c.MaxConnIdleTime > 0 {
+		opts.SetMaxConnIdleTime(c.MaxConnIdleTime)
+	}
+
+	if c.MinPoolSize > 0 {
+		opts.SetMinPoolSize(c.MinPoolSize)
+	}
+
+	if c.AuthSource != "" && opts.Auth != nil {
+		opts.Auth.AuthSource = c.AuthSource
+	}
+
+	if c.ReplicaSet != "" {
+		opts.SetReplicaSet(c.ReplicaSet)
+	}
+
+	if c.AppName != "" {
+		opts.SetAppName(c.AppName)
+	}
+
+	if c.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(c.ConnectTimeout)
+	}
+
+	if c.SocketTimeout > 0 {
+		opts.SetSocketTimeout(c.SocketTimeout)
+	}
+
+	if c.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(c.ServerSelectionTimeout)
+	}
+
+	if c.RetryWrites != nil {
+		opts.SetRetryWrites(*c.RetryWrites)
+	}
+
+	if c.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(c.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.SetReadPreference(rp)
+	}
+
+	if c.WriteConcern != "" {
+		wc, err := c.getWriteConcern()
+		if err != nil {
+			return nil, err
+		}
+
+		opts.SetWriteConcern(wc)
+	}
+
+	if c.TLS {
+		tlsConf, err := c.getTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		opts.SetTLSConfig(tlsConf)
+	}
+
+	return opts, opts.Validate()
+}
+
+func (c MongoConf) getWriteConcern() (*writeconcern.WriteConcern, error) {
+	if c.WriteConcern == "majority" {
+		return writeconcern.New(writeconcern.WMajority()), nil
+	}
+
+	w, err := strconv.Atoi(c.WriteConcern)
+	if err != nil || w < 0 {
+		return nil, fmt.Errorf("invalid write concern: %s", c.WriteConcern)
+	}
+
+	return writeconcern.New(writeconcern.W(w)), nil
+}
+
+func (c MongoConf) getTLSConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CACertFile != "" {
+		caCert, err := os.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败,err:%w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("CA证书格式错误")
+		}
+
+		conf.RootCAs = pool
+	}
+
+	if c.CertFile != "" {
+		keyFile := c.KeyFile
+		if keyFile == "" {
+			keyFile = c.CertFile
+		}
+
+		cert, err := tls.LoadX509KeyPair(c.CertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端证书失败,err:%w", err)
+		}
+
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
+// RedactURI 隐藏 uri 中的密码.
+func RedactURI(uri string) string {
+	if uri == "" {
+		return ""
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "<invalid uri>"
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedPassword)
+	}
+
+	return u.String()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"reflect"
 	"strconv"
@@ -14,6 +15,7 @@ import (
 	"github.com/qumogu/go-tools/httpserver"
 	"github.com/xuri/excelize/v2"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // type CRUD interface {
@@ -53,6 +55,10 @@ type UploadExcelSupported interface {
 	UploadExcelController(c *gin.Context)
 }
 
+type RestoreSupported interface {
+	RestoreController(c *gin.Context)
+}
+
 // Tile对应的json key
 type ExcelColumn struct {
 	Key          string
@@ -75,19 +81,237 @@ type Crud struct {
 
 	collection string
 	database   string
+
+	softDelete bool   // 删除接口为假删除, 查询排除已删除的记录
+	userKey    string // gin 上下文中当前用户名的 key, 用于记录删除人
+
+	tenantScope bool                      // 按租户隔离数据, 见 TenantBase
+	orgKey      string                    // gin 上下文中租户ID的 key
+	superAdmin  func(c *gin.Context) bool // 返回 true 时不按租户隔离
+
+	resolver TenantResolver // 按请求选择租户数据库
+
+	sort SortConfig // 列表与导出接口的排序
+
+	cursorPage bool // 列表接口使用游标分页
+
+	versioned bool // 更新接口按版本号更新, 见 Base.WithVersion
+}
+
+// SortConfig 列表与导出接口的排序配置, 请求参数如 sort=-created_time,name.
+type SortConfig struct {
+	Fields    []string           // 允许排序的字段, 应当建有索引, 为空时不支持请求参数排序
+	Default   string             // 没有排序参数时的排序, 应当使用有索引的字段, 默认 -_id
+	Param     string             // 排序的查询参数名, 默认 sort
+	Collation *options.Collation // 排序规则, 默认 DefaultCollation
+}
+
+const (
+	defaultUserKey   = "user_name"
+	defaultOrgKey    = CtxOrgID
+	defaultSort      = "-_id"
+	defaultSortParam = "sort"
+)
+
+// CrudOption Crud 的可选配置.
+type CrudOption interface {
+	apply(*Crud)
+}
+
+type crudOptionFunc func(*Crud)
+
+func (f crudOptionFunc) apply(d *Crud) {
+	f(d)
+}
+
+// CrudOptionSoftDelete 开启假删除, 删除接口记录删除时间与删除人, 并提供恢复接口.
+// userKey 为 gin 上下文中当前用户名的 key, 为空时使用 user_name.
+func CrudOptionSoftDelete(userKey string) CrudOption {
+	return crudOptionFunc(func(d *Crud) {
+		d.softDelete = true
+
+		if userKey != "" {
+			d.userKey = userKey
+		}
+	})
+}
+
+// CrudOptionTenantScope 按租户隔离数据, 所有接口只能读写当前租户的记录, 创建时写入 org_id.
+// orgKey 为 gin 上下文中租户ID的 key, 为空时使用 org_id; 请求中没有租户ID时返回 403.
+func CrudOptionTenantScope(orgKey string) CrudOption {
+	return crudOptionFunc(func(d *Crud) {
+		d.tenantScope = true
+
+		if orgKey != "" {
+			d.orgKey = orgKey
+		}
+	})
+}
+
+// CrudOptionSuperAdmin 开启租户隔离时, isSuperAdmin 返回 true 的请求可以读写所有租户的记录.
+func CrudOptionSuperAdmin(isSuperAdmin func(c *gin.Context) bool) CrudOption {
+	return crudOptionFunc(func(d *Crud) {
+		d.superAdmin = isSuperAdmin
+	})
+}
+
+// CrudOptionTenantResolver 使用 resolver 得到的租户作为数据库名称, 优先于 NewCrud 的 database.
+//
+//	mongodb.CrudOptionTenantResolver(mongodb.AllowTenants(
+//		mongodb.ChainTenantResolver(
+//			mongodb.ContextTenantResolver(model.CTX_ORG_ID),
+//			mongodb.HeaderTenantResolver(""),
+//		),
+//		"org-101", "org-102",
+//	))
+func CrudOptionTenantResolver(resolver TenantResolver) CrudOption {
+	return crudOptionFunc(func(d *Crud) {
+		d.resolver = resolver
+	})
+}
+
+// CrudOptionSort 设置允许排序的字段、默认排序与排序规则.
+//
+//	mongodb.CrudOptionSort(mongodb.SortConfig{
+//		Fields:  []string{"created_time", "name"},
+//		Default: "-created_time",
+//	})
+func CrudOptionSort(conf SortConfig) CrudOption {
+	return crudOptionFunc(func(d *Crud) {
+		if conf.Default == "" {
+			conf.Default = defaultSort
+		}
+
+		if conf.Param == "" {
+			conf.Param = defaultSortParam
+		}
+
+		d.sort = conf
+	})
+}
+
+// CrudOptionCursorPagination 列表接口使用游标分页, 不统计总数也不使用 offset, 适用于大集合.
+// 请求参数 cursor 为上一页返回的 next_cursor, with_total=true 时同时返回总数, 导出接口不受影响.
+func CrudOptionCursorPagination() CrudOption {
+	return crudOptionFunc(func(d *Crud) {
+		d.cursorPage = true
+	})
+}
+
+// CrudOptionVersion 开启版本号(乐观锁), 详情接口通过 ETag 返回版本号,
+// 更新接口需要通过 If-Match 请求头或请求体中的 version 传入读取时的版本号,
+// 没有版本号时返回 428, 记录已被其他请求修改时返回 409.
+func CrudOptionVersion() CrudOption {
+	return crudOptionFunc(func(d *Crud) {
+		d.versioned = true
+	})
+}
+
+// pageOrder 返回分页与请求的排序, 排序字段不在 SortConfig.Fields 中时返回 ErrInvalidSort.
+func (d *Crud) pageOrder(c *gin.Context, limit, offset int64) (PageOrder, error) {
+	pageOrder := NewPageOrder(limit, offset)
+	pageOrder.Collation = d.sort.Collation
+	pageOrder.Sort = d.sort.Default
+
+	sort := c.Query(d.sort.Param)
+	if sort == "" {
+		return pageOrder, nil
+	}
+
+	if len(d.sort.Fields) == 0 {
+		return pageOrder, fmt.Errorf("%w: sorting is not supported", ErrInvalidSort)
+	}
+
+	if _, err := ParseSort(sort, d.sort.Fields...); err != nil {
+		return pageOrder, err
+	}
+
+	pageOrder.Sort = sort
+
+	return pageOrder, nil
+}
+
+// context 返回请求的 context, 开启租户隔离时带有租户ID或超级管理员标记.
+func (d *Crud) context(c *gin.Context) (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+
+	if d.tenantScope {
+		if d.superAdmin != nil && d.superAdmin(c) {
+			ctx = WithSuperAdmin(ctx)
+		} else {
+			ctx = WithOrgID(ctx, c.GetString(d.orgKey))
+		}
+	}
+
+	return context.WithTimeout(ctx, time.Second*10)
 }
 
-func (d *Crud) getMongoBase(c *gin.Context) *MongoBase {
+// failure 租户与数据库错误返回对应的 http 状态码, 其他错误返回 errCode.
+func (d *Crud) failure(c *gin.Context, errCode int, err error) {
+	switch {
+	case errors.Is(err, ErrNoTenant), errors.Is(err, ErrTenantMismatch), errors.Is(err, ErrTenantNotAllowed):
+		httpserver.FailureWithHTTPStatus(c, http.StatusForbidden, ErrTenantCodeKey, err.Error())
+
+		return
+	case errors.Is(err, ErrTenantNotResolved), errors.Is(err, ErrInvalidTenant):
+		httpserver.FailureWithHTTPStatus(c, http.StatusBadRequest, ErrTenantCodeKey, err.Error())
+
+		return
+	case errors.Is(err, ErrConflict):
+		httpserver.FailureWithHTTPStatus(c, http.StatusConflict, ErrConflictCodeKey, err.Error())
+
+		return
+	case errors.Is(err, ErrMongoNotInit):
+		httpserver.FailureWithHTTPStatus(c, http.StatusServiceUnavailable, FailureExit, err.Error())
+
+		return
+	}
+
+	httpserver.Failure(c, errCode, err.Error())
+}
 
+// getMongoBase 返回请求对应数据库的集合, 设置了 resolver 时数据库为请求的租户.
+func (d *Crud) getMongoBase(c *gin.Context) (BaseInterfc, error) {
 	database := d.database
 
+	if d.resolver != nil {
+		tenant, err := d.resolver.Resolve(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if !validDatabaseName(tenant) {
+			return nil, ErrInvalidTenant
+		}
+
+		database = tenant
+	}
+
+	if DefaultMongoMgr == nil {
+		return nil, ErrMongoNotInit
+	}
+
 	mongoDB, err := DefaultMongoMgr.GetDB(database)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	collection := mongoDB.Collection(d.collection)
-	return NewCrudBase(collection)
+
+	base := NewBase(collection)
+	if d.softDelete {
+		base = NewSoftDeleteBase(collection)
+	}
+
+	if d.versioned {
+		base = base.WithVersion()
+	}
+
+	if d.tenantScope {
+		return NewTenantBase(base), nil
+	}
+
+	return base, nil
 }
 
 type ResulListtVO struct {
@@ -111,8 +335,9 @@ type Param interface {
 }
 
 // CRUD 库
-// database 为空说明分多租户, 租户ID为数据库名称
-func NewCrud(database, collection string, param Param) *Crud {
+// database 为空说明分多租户, 租户ID为数据库名称, 默认从 gin 上下文的 org_id 获取租户ID,
+// 可以使用 CrudOptionTenantResolver 从请求头、子域名等获取并限制允许的租户.
+func NewCrud(database, collection string, param Param, opts ...CrudOption) *Crud {
 	// tileMap:= map[string]int{
 	// 	"name": 1,
 	// 	"age":2,
@@ -130,7 +355,7 @@ func NewCrud(database, collection string, param Param) *Crud {
 		tileMap[col.Key] = idx + 1
 	}
 
-	return &Crud{
+	d := &Crud{
 		collection: collection,
 		// newModel :newModel,
 		// newCreate:newCreate,
@@ -141,11 +366,28 @@ func NewCrud(database, collection string, param Param) *Crud {
 		param:         param,
 		tileMap:       tileMap,
 		exportColumns: columns,
+		userKey:       defaultUserKey,
+		orgKey:        defaultOrgKey,
+		sort:          SortConfig{Default: defaultSort, Param: defaultSortParam},
+	}
+
+	for _, opt := range opts {
+		opt.apply(d)
+	}
+
+	if _, err := ParseSort(d.sort.Default); err != nil {
+		panic(err)
 	}
+
+	if d.database == "" && d.resolver == nil {
+		d.resolver = ContextTenantResolver(d.orgKey)
+	}
+
+	return d
 }
 
 func (d *Crud) CreateController(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := d.context(c)
 	defer cancel()
 	data := d.param.NewCreate(c)
 	err := c.ShouldBind(data)
@@ -155,10 +397,16 @@ func (d *Crud) CreateController(c *gin.Context) {
 		return
 	}
 
-	mongobase := d.getMongoBase(c)
+	mongobase, err := d.getMongoBase(c)
+	if err != nil {
+		d.failure(c, FailureExit, err)
+
+		return
+	}
+
 	_, err = mongobase.Create(ctx, data)
 	if err != nil {
-		httpserver.Failure(c, ErrCreateFailedCodeKey, err.Error())
+		d.failure(c, ErrCreateFailedCodeKey, err)
 
 		return
 	}
@@ -170,14 +418,20 @@ func (d *Crud) CreateController(c *gin.Context) {
 //
 // 只适用于 mongodb
 //
-// 查询参数结构体属性为指针, 反射遍历, 拼接mongo filter为 ==
+// 查询参数结构体属性为指针或切片, 反射遍历, 拼接mongo filter, 操作符见 BuildFilter
 // Limit 与 offset 字段
 func (d *Crud) ListController(c *gin.Context) {
+	if d.cursorPage {
+		d.cursorList(c)
+
+		return
+	}
+
 	// s := d.search
 	// s := Stu{}
 	total, results, err := d.list(c)
 	if err != nil {
-		httpserver.Failure(c, ErrParamParseCodeKey, err.Error())
+		d.failure(c, ErrParamParseCodeKey, err)
 
 		return
 	}
@@ -190,10 +444,23 @@ func (d *Crud) ListController(c *gin.Context) {
 	httpserver.Success(c, resultVo)
 }
 
-func (d *Crud) list(c *gin.Context) (int64, interface{}, error) {
-	mongobase := d.getMongoBase(c)
+// search 绑定查询参数并生成过滤条件, 返回分页字段 Limit 与 Offset.
+func (d *Crud) search(c *gin.Context) (bson.M, *int64, *int64, error) {
 	s := d.param.NewSearch(c)
-	err := c.BindQuery(s)
+	if err := c.BindQuery(s); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return parseSearch(s)
+}
+
+func (d *Crud) list(c *gin.Context) (int64, interface{}, error) {
+	mongobase, err := d.getMongoBase(c)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	filter, limitPtr, offsetPtr, err := d.search(c)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -203,61 +470,112 @@ func (d *Crud) list(c *gin.Context) (int64, interface{}, error) {
 		offset = int64(0)
 	)
 
-	filter := bson.M{}
-	refType := reflect.TypeOf(s).Elem()
-	refValue := reflect.ValueOf(s).Elem()
+	if limitPtr != nil {
+		limit = *limitPtr
+	}
 
-	fieldsN := refValue.NumField()
-	for i := 0; i < fieldsN; i++ {
-		sf := refType.Field(i)
-		rv := refValue.Field(i)
+	if offsetPtr != nil {
+		offset = *offsetPtr
+	}
 
-		if sf.Type.Kind() == reflect.Ptr {
-			// sf.Type.Elem().
-			if rv.IsNil() {
-				continue
-			}
+	pageOrder, err := d.pageOrder(c, limit, offset)
+	if err != nil {
+		return 0, nil, err
+	}
 
-			if sf.Name == "Limit" {
-				limit = rv.Elem().Int()
-				continue
-			}
+	ctx, cancel := d.context(c)
+	defer cancel()
 
-			if sf.Name == "Offset" {
-				offset = rv.Elem().Int()
-				continue
-			}
+	list := d.param.NewListResult(c)
+	total, err := mongobase.FindWithPage(ctx, filter, pageOrder, list)
+	if err != nil {
+		return 0, nil, err
+	}
 
-			bsTagStr := sf.Tag.Get("bson")
-			bsTags := strings.Split(bsTagStr, ",")
-			name := sf.Name
-			if bsTags[0] != "" {
-				name = bsTags[0]
-			}
+	return total, list, nil
+}
 
-			v := rv.Elem().Interface()
-			// fmt.Printf("%v %s:%v\n", len(bsTags), name, v)
-			filter[name] = v
-		}
+// 游标分页查询, 见 CrudOptionCursorPagination
+func (d *Crud) cursorList(c *gin.Context) {
+	mongobase, err := d.getMongoBase(c)
+	if err != nil {
+		d.failure(c, FailureExit, err)
+
+		return
 	}
 
-	pageOrder := NewPageOrder(limit, offset)
+	filter, limitPtr, _, err := d.search(c)
+	if err != nil {
+		httpserver.Failure(c, ErrParamParseCodeKey, err.Error())
+
+		return
+	}
+
+	limit := int64(defaultCursorLimit)
+	if limitPtr != nil {
+		limit = *limitPtr
+	}
+
+	pageOrder, err := d.pageOrder(c, limit, 0)
+	if err != nil {
+		httpserver.Failure(c, ErrParamParseCodeKey, err.Error())
+
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := d.context(c)
 	defer cancel()
 
+	query := CursorQuery{
+		Sort:      pageOrder.Sort,
+		Limit:     limit,
+		Cursor:    c.Query("cursor"),
+		Collation: pageOrder.Collation,
+	}
+
 	list := d.param.NewListResult(c)
-	total, results, err := mongobase.FindWithPage(ctx, filter, pageOrder, list)
+
+	next, err := mongobase.FindWithCursor(ctx, filter, query, list)
 	if err != nil {
-		return 0, nil, err
+		if errors.Is(err, ErrInvalidCursor) {
+			httpserver.Failure(c, ErrParamParseCodeKey, err.Error())
+
+			return
+		}
+
+		d.failure(c, FailureExit, err)
+
+		return
+	}
+
+	result := CursorPage{
+		Data:       list,
+		NextCursor: next,
 	}
 
-	return total, results, nil
+	if c.Query("with_total") == "true" {
+		total, err := mongobase.Count(ctx, filter)
+		if err != nil {
+			d.failure(c, FailureExit, err)
+
+			return
+		}
+
+		result.TotalCount = &total
+	}
+
+	httpserver.Success(c, result)
 }
 
 func (d *Crud) InfoController(c *gin.Context) {
-	mongobase := d.getMongoBase(c)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	mongobase, err := d.getMongoBase(c)
+	if err != nil {
+		d.failure(c, FailureExit, err)
+
+		return
+	}
+
+	ctx, cancel := d.context(c)
 	defer cancel()
 
 	ID, ok := c.Params.Get("id")
@@ -271,7 +589,7 @@ func (d *Crud) InfoController(c *gin.Context) {
 
 	result := d.param.NewModel(c)
 	// result := Student{}
-	err := mongobase.FindByID(ctx, ID, &result)
+	err = mongobase.FindOneByID(ctx, ID, &result)
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
 			httpserver.Success(c, map[string]string{})
@@ -279,19 +597,31 @@ func (d *Crud) InfoController(c *gin.Context) {
 			return
 		}
 
-		httpserver.Failure(c, FailureExit, err.Error())
+		d.failure(c, FailureExit, err)
 
 		return
 	}
 
 	// fmt.Printf("%+v\n", result)
 
+	if d.versioned {
+		if version, ok := versionOf(result); ok {
+			c.Header("ETag", FormatVersion(version))
+		}
+	}
+
 	httpserver.Success(c, result)
 }
 
 func (d *Crud) UpdateController(c *gin.Context) {
-	mongobase := d.getMongoBase(c)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	mongobase, err := d.getMongoBase(c)
+	if err != nil {
+		d.failure(c, FailureExit, err)
+
+		return
+	}
+
+	ctx, cancel := d.context(c)
 	defer cancel()
 	updateParam := d.param.NewUpdate(c)
 	if err := c.ShouldBind(&updateParam); err != nil {
@@ -307,19 +637,69 @@ func (d *Crud) UpdateController(c *gin.Context) {
 		return
 	}
 
-	err := mongobase.UpdateByID(ctx, ID, updateParam)
+	if !d.versioned {
+		err = mongobase.UpdateByID(ctx, ID, updateParam)
+		if err != nil {
+			d.failure(c, FailureExit, err)
+
+			return
+		}
+
+		httpserver.Success(c, nil)
+
+		return
+	}
+
+	version, ok, err := expectedVersion(c, updateParam)
 	if err != nil {
-		httpserver.Failure(c, FailureExit, err.Error())
+		httpserver.Failure(c, ErrParamParseCodeKey, err.Error())
 
 		return
 	}
 
+	if !ok {
+		httpserver.FailureWithHTTPStatus(c, http.StatusPreconditionRequired, ErrVersionRequiredCodeKey,
+			"version is required in If-Match header or body")
+
+		return
+	}
+
+	err = mongobase.UpdateByIDWithVersion(ctx, ID, version, updateParam)
+	if err != nil {
+		d.failure(c, FailureExit, err)
+
+		return
+	}
+
+	c.Header("ETag", FormatVersion(version+1))
 	httpserver.Success(c, nil)
 }
 
+// expectedVersion 返回更新请求中的版本号, If-Match 请求头优先于请求体中的 version.
+func expectedVersion(c *gin.Context, updateParam interface{}) (int64, bool, error) {
+	if etag := c.GetHeader("If-Match"); etag != "" {
+		version, err := ParseVersion(etag)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid If-Match: %w", err)
+		}
+
+		return version, true, nil
+	}
+
+	version, ok := versionOf(updateParam)
+
+	return version, ok, nil
+}
+
 func (d *Crud) DeleteController(c *gin.Context) {
-	mongobase := d.getMongoBase(c)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	mongobase, err := d.getMongoBase(c)
+	if err != nil {
+		d.failure(c, FailureExit, err)
+
+		return
+	}
+
+	ctx, cancel := d.context(c)
 	defer cancel()
 	param := &DeleteParam{}
 	if err := c.ShouldBind(&param); err != nil {
@@ -329,9 +709,49 @@ func (d *Crud) DeleteController(c *gin.Context) {
 	}
 
 	ids := param.IDs
-	_, err := mongobase.DeleteByIds(ctx, ids)
+
+	if d.softDelete {
+		_, err = mongobase.SoftDeleteByIds(ctx, ids, c.GetString(d.userKey))
+	} else {
+		_, err = mongobase.DeleteByIds(ctx, ids)
+	}
+
 	if err != nil {
-		httpserver.Failure(c, FailureExit, err.Error())
+		d.failure(c, FailureExit, err)
+
+		return
+	}
+
+	httpserver.Success(c, nil)
+}
+
+// 恢复假删除的记录, 需要开启 CrudOptionSoftDelete
+func (d *Crud) RestoreController(c *gin.Context) {
+	if !d.softDelete {
+		httpserver.Failure(c, ErrSoftDeleteDisabledCodeKey, "soft delete is not enabled")
+
+		return
+	}
+
+	mongobase, err := d.getMongoBase(c)
+	if err != nil {
+		d.failure(c, FailureExit, err)
+
+		return
+	}
+
+	ctx, cancel := d.context(c)
+	defer cancel()
+	param := &DeleteParam{}
+	if err := c.ShouldBind(&param); err != nil {
+		httpserver.Failure(c, ErrParamParseCodeKey, err.Error())
+
+		return
+	}
+
+	_, err = mongobase.Restore(ctx, param.IDs)
+	if err != nil {
+		d.failure(c, FailureExit, err)
 
 		return
 	}
@@ -343,7 +763,7 @@ func (d *Crud) DeleteController(c *gin.Context) {
 func (d *Crud) ExportExcelController(c *gin.Context) {
 	_, results, err := d.list(c)
 	if err != nil {
-		httpserver.Failure(c, ErrParamParseCodeKey, err.Error())
+		d.failure(c, ErrParamParseCodeKey, err)
 
 		return
 	}
@@ -452,4 +872,8 @@ func CRUD(group *gin.RouterGroup, path string, resource interface{}) {
 	if resource, ok := resource.(UploadExcelSupported); ok {
 		group.POST(path+"/excel", resource.UploadExcelController)
 	}
+
+	if resource, ok := resource.(RestoreSupported); ok {
+		group.POST(path+"/restore", resource.RestoreController)
+	}
 }
@@ -8,7 +8,6 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var (
@@ -89,9 +88,9 @@ func (b *MongoBase) UpdateByID(ctx context.Context, id string, data interface{})
 // }
 
 func (b *MongoBase) FindWithPage(ctx context.Context, filter interface{}, pageOrder PageOrderIntfc, results interface{}) (int64, interface{}, error) {
-	var findOpt *options.FindOptions
-	if pageOrder != nil {
-		findOpt = pageOrder.GetMongoFindOptions()
+	findOpt, err := findOptions(pageOrder)
+	if err != nil {
+		return 0, nil, err
 	}
 
 	totalCount, err := b.C.CountDocuments(ctx, filter)
@@ -0,0 +1,58 @@
+package mongodb
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testParam struct{}
+
+func (testParam) NewModel(c *gin.Context) interface{}      { return &map[string]interface{}{} }
+func (testParam) NewCreate(c *gin.Context) interface{}     { return &map[string]interface{}{} }
+func (testParam) NewSearch(c *gin.Context) interface{}     { return &struct{}{} }
+func (testParam) NewUpdate(c *gin.Context) interface{}     { return &map[string]interface{}{} }
+func (testParam) NewListResult(c *gin.Context) interface{} { return &[]map[string]interface{}{} }
+func (testParam) ExcelColumns() []ExcelColumn              { return []ExcelColumn{{Key: "name", Name: "名称"}} }
+func (testParam) ExcelName() string                        { return "test" }
+
+func TestCrud_PageOrder(t *testing.T) {
+	newContext := func(query string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/message?"+query, nil)
+
+		return c
+	}
+
+	d := NewCrud("test", "message", testParam{})
+
+	p, err := d.pageOrder(newContext(""), 10, 0)
+	if err != nil || p.Sort != defaultSort {
+		t.Errorf("sort = %q, err = %v, want default sort", p.Sort, err)
+	}
+
+	if _, err = d.pageOrder(newContext("sort=name"), 10, 0); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("err = %v, sorting should be disabled without whitelist", err)
+	}
+
+	d = NewCrud("test", "message", testParam{}, CrudOptionSort(SortConfig{
+		Fields:  []string{"created_time", "name"},
+		Default: "-created_time",
+	}))
+
+	p, err = d.pageOrder(newContext("sort=-created_time,name"), 10, 0)
+	if err != nil || p.Sort != "-created_time,name" {
+		t.Errorf("sort = %q, err = %v", p.Sort, err)
+	}
+
+	if _, err = d.pageOrder(newContext("sort=password"), 10, 0); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("err = %v, want ErrInvalidSort", err)
+	}
+
+	if p, _ = d.pageOrder(newContext(""), 10, 0); p.Sort != "-created_time" {
+		t.Errorf("sort = %q, want -created_time", p.Sort)
+	}
+}
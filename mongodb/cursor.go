@@ -0,0 +1,233 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultCursorLimit = 10
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorQuery 游标分页参数.
+type CursorQuery struct {
+	Sort      string             // 排序, 格式同 ParseSort, 总是以 _id 作为最后的排序字段, 默认 -_id
+	Limit     int64              // 每页大小, 默认10
+	Cursor    string             // 上一页返回的 next_cursor, 为空时查询第一页
+	Collation *options.Collation // 排序规则, 为 nil 时使用 DefaultCollation
+}
+
+// CursorPage 游标分页结果, NextCursor 为空表示没有下一页.
+type CursorPage struct {
+	Data       interface{} `json:"data"` // 切片
+	NextCursor string      `json:"next_cursor"`
+	TotalCount *int64      `json:"total_count,omitempty"` // 只有请求总数时返回
+}
+
+// cursorToken 游标的内容, 保存排序与上一页最后一条记录的排序字段值.
+type cursorToken struct {
+	Sort   string          `bson:"s"`
+	Values []bson.RawValue `bson:"v"`
+}
+
+// FindWithCursor 游标(keyset)分页查询, 不统计总数也不使用 skip, 适用于大集合的深度分页.
+// results 为切片指针, 返回下一页的游标, 没有下一页时为空.
+// 排序字段应当建有索引, 且不应缺失或为 null, 否则这些记录的翻页结果不确定.
+func (b *Base) FindWithCursor(ctx context.Context, filter interface{}, q CursorQuery, results interface{}) (string, error) {
+	sort, err := cursorSort(q.Sort)
+	if err != nil {
+		return "", err
+	}
+
+	sortKey := sortString(sort)
+
+	filter = b.notDeleted(filter)
+
+	if q.Cursor != "" {
+		token, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return "", err
+		}
+
+		if token.Sort != sortKey || len(token.Values) != len(sort) {
+			return "", fmt.Errorf("%w: sort changed", ErrInvalidCursor)
+		}
+
+		filter = addCondition(filter, "$or", afterCondition(sort, token.Values))
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultCursorLimit
+	}
+
+	collation := q.Collation
+	if collation == nil {
+		collation = DefaultCollation
+	}
+
+	// 多查询一条判断是否有下一页
+	findOpt := options.Find().SetSort(sort).SetLimit(limit + 1)
+	if collation != nil {
+		findOpt.SetCollation(collation)
+	}
+
+	ctx, cancel := NewContextWithTimeout(ctx)
+	defer cancel()
+
+	cursor, err := b.collection.Find(ctx, filter, findOpt)
+	if err != nil {
+		return "", err
+	}
+
+	raws := make([]bson.Raw, 0, limit+1)
+	if err = cursor.All(ctx, &raws); err != nil {
+		return "", err
+	}
+
+	hasMore := int64(len(raws)) > limit
+	if hasMore {
+		raws = raws[:limit]
+	}
+
+	if err = decodeRaws(raws, results); err != nil {
+		return "", err
+	}
+
+	if !hasMore {
+		return "", nil
+	}
+
+	return encodeCursor(sort, sortKey, raws[len(raws)-1])
+}
+
+// cursorSort 解析排序并以 _id 作为最后的排序字段, 保证排序唯一.
+func cursorSort(s string) (bson.D, error) {
+	sort, err := ParseSort(s)
+	if err != nil {
+		return nil, err
+	}
+
+	order := -1
+
+	for _, e := range sort {
+		if e.Key == "_id" {
+			return sort, nil
+		}
+
+		order = e.Value.(int)
+	}
+
+	return append(sort, primitive.E{Key: "_id", Value: order}), nil
+}
+
+func sortString(sort bson.D) string {
+	fields := make([]string, 0, len(sort))
+
+	for _, e := range sort {
+		if e.Value.(int) < 0 {
+			fields = append(fields, "-"+e.Key)
+		} else {
+			fields = append(fields, e.Key)
+		}
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// afterCondition 排在游标之后的记录, 如排序 a 降序、_id 降序时为
+// [{a: {$lt: va}}, {a: va, _id: {$lt: vid}}].
+func afterCondition(sort bson.D, values []bson.RawValue) bson.A {
+	or := make(bson.A, 0, len(sort))
+
+	for i, e := range sort {
+		cond := make(bson.D, 0, i+1)
+		for j := 0; j < i; j++ {
+			cond = append(cond, primitive.E{Key: sort[j].Key, Value: values[j]})
+		}
+
+		op := "$gt"
+		if e.Value.(int) < 0 {
+			op = "$lt"
+		}
+
+		cond = append(cond, primitive.E{Key: e.Key, Value: bson.M{op: values[i]}})
+		or = append(or, cond)
+	}
+
+	return or
+}
+
+func encodeCursor(sort bson.D, sortKey string, last bson.Raw) (string, error) {
+	token := cursorToken{Sort: sortKey, Values: make([]bson.RawValue, 0, len(sort))}
+
+	for _, e := range sort {
+		v, err := last.LookupErr(strings.Split(e.Key, ".")...)
+		if err != nil {
+			return "", fmt.Errorf("sort field %s: %w", e.Key, err)
+		}
+
+		token.Values = append(token.Values, v)
+	}
+
+	data, err := bson.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string) (*cursorToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	token := &cursorToken{}
+	if err = bson.Unmarshal(data, token); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// 游标由客户端传入, 文档或数组会作为查询操作符, 如 {$ne: null}, 只允许标量值
+	for _, v := range token.Values {
+		if v.Type == bsontype.EmbeddedDocument || v.Type == bsontype.Array {
+			return nil, fmt.Errorf("%w: sort value should be scalar", ErrInvalidCursor)
+		}
+	}
+
+	return token, nil
+}
+
+// decodeRaws 将查询结果解码到切片指针 results 中.
+func decodeRaws(raws []bson.Raw, results interface{}) error {
+	rv := reflect.ValueOf(results)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("results should be a pointer to slice")
+	}
+
+	slice := rv.Elem()
+	list := reflect.MakeSlice(slice.Type(), 0, len(raws))
+
+	for _, raw := range raws {
+		elem := reflect.New(slice.Type().Elem())
+		if err := bson.Unmarshal(raw, elem.Interface()); err != nil {
+			return err
+		}
+
+		list = reflect.Append(list, elem.Elem())
+	}
+
+	slice.Set(list)
+
+	return nil
+}
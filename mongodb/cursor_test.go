@@ -0,0 +1,119 @@
+package mongodb
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorSort(t *testing.T) {
+	sort, err := cursorSort("-created_time,name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := bson.D{{Key: "created_time", Value: -1}, {Key: "name", Value: 1}, {Key: "_id", Value: 1}}
+	if !reflect.DeepEqual(sort, want) {
+		t.Errorf("got %v, want %v", sort, want)
+	}
+
+	if sort, _ = cursorSort(""); !reflect.DeepEqual(sort, bson.D{{Key: "_id", Value: -1}}) {
+		t.Errorf("default sort = %v, want -_id", sort)
+	}
+
+	if got := sortString(want); got != "-created_time,name,_id" {
+		t.Errorf("sort string = %q", got)
+	}
+}
+
+func TestCursor_RoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	sort := bson.D{{Key: "created_time", Value: -1}, {Key: "_id", Value: -1}}
+
+	last, _ := bson.Marshal(bson.M{"_id": id, "created_time": int64(100), "name": "a"})
+
+	cursor, err := encodeCursor(sort, sortString(sort), last)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := decodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.Sort != "-created_time,-_id" || len(token.Values) != 2 {
+		t.Fatalf("unexpected token: %+v", token)
+	}
+
+	if token.Values[0].Int64() != 100 || token.Values[1].ObjectID() != id {
+		t.Errorf("unexpected values: %v", token.Values)
+	}
+
+	// 下一页条件: created_time < 100 或 created_time = 100 且 _id < id
+	got, _ := bson.MarshalExtJSON(bson.M{"$or": afterCondition(sort, token.Values)}, false, false)
+	want, _ := bson.MarshalExtJSON(bson.M{"$or": bson.A{
+		bson.D{{Key: "created_time", Value: bson.M{"$lt": int64(100)}}},
+		bson.D{{Key: "created_time", Value: int64(100)}, {Key: "_id", Value: bson.M{"$lt": id}}},
+	}}, false, false)
+	if string(got) != string(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if _, err = decodeCursor("not a cursor"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("err = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestDecodeCursor_Forged(t *testing.T) {
+	forge := func(v interface{}) string {
+		typ, raw, _ := bson.MarshalValue(v)
+
+		data, _ := bson.Marshal(cursorToken{
+			Sort:   "-_id",
+			Values: []bson.RawValue{{Type: typ, Value: raw}},
+		})
+
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	for _, v := range []interface{}{
+		bson.M{"$ne": nil},
+		bson.M{"$regex": ".*"},
+		bson.A{1, 2},
+	} {
+		if _, err := decodeCursor(forge(v)); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("forged cursor %v: err = %v, want ErrInvalidCursor", v, err)
+		}
+	}
+
+	if _, err := decodeCursor(forge(primitive.NewObjectID())); err != nil {
+		t.Errorf("scalar cursor value should be accepted, err = %v", err)
+	}
+}
+
+func TestDecodeRaws(t *testing.T) {
+	a, _ := bson.Marshal(bson.M{"name": "a"})
+	b, _ := bson.Marshal(bson.M{"name": "b"})
+
+	type doc struct {
+		Name string `bson:"name"`
+	}
+
+	var results []*doc
+	if err := decodeRaws([]bson.Raw{a, b}, &results); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 || results[0].Name != "a" || results[1].Name != "b" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	if err := decodeRaws(nil, results); err == nil {
+		t.Error("expect error for non-pointer results")
+	}
+}
@@ -1,8 +1,12 @@
 package mongodb
 
 const (
-	SuccessExit            = 0
-	FailureExit            = -27017
-	ErrParamParseCodeKey   = 27001
-	ErrCreateFailedCodeKey = 27002
+	SuccessExit                  = 0
+	FailureExit                  = -27017
+	ErrParamParseCodeKey         = 27001
+	ErrCreateFailedCodeKey       = 27002
+	ErrSoftDeleteDisabledCodeKey = 27003
+	ErrTenantCodeKey             = 27004
+	ErrConflictCodeKey           = 27005
+	ErrVersionRequiredCodeKey    = 27006
 )
@@ -0,0 +1,251 @@
+package mongodb
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 查询条件的操作符, 在查询参数结构体中用 filter tag 声明, 如 `filter:"created_time,op=gte"`.
+const (
+	FilterOpEq     = "eq"     // 等于, 非切片字段的默认操作符
+	FilterOpNe     = "ne"     // 不等于
+	FilterOpGt     = "gt"     // 大于
+	FilterOpGte    = "gte"    // 大于等于
+	FilterOpLt     = "lt"     // 小于
+	FilterOpLte    = "lte"    // 小于等于
+	FilterOpIn     = "in"     // 属于切片中的任一值, 切片字段的默认操作符
+	FilterOpNin    = "nin"    // 不属于切片中的任何值
+	FilterOpRegex  = "regex"  // 包含字符串, 不区分大小写, 特殊字符按原样匹配
+	FilterOpExists = "exists" // 字段是否存在, 字段类型为 bool
+)
+
+var filterOperators = map[string]string{
+	FilterOpEq:     "$eq",
+	FilterOpNe:     "$ne",
+	FilterOpGt:     "$gt",
+	FilterOpGte:    "$gte",
+	FilterOpLt:     "$lt",
+	FilterOpLte:    "$lte",
+	FilterOpIn:     "$in",
+	FilterOpNin:    "$nin",
+	FilterOpRegex:  "$regex",
+	FilterOpExists: "$exists",
+}
+
+// BuildFilter 根据查询参数结构体生成 mongo 过滤条件.
+//
+// 只有指针与切片字段参与过滤, nil 指针与空切片被忽略, 匿名嵌入的结构体会展开.
+// 字段名依次取 filter tag、bson tag 的名称与结构体字段名, filter:"-" 的字段被忽略,
+// 分页字段 Limit、Offset 与排序字段 Sort 不作为过滤条件. 同一字段的多个条件合并, 如:
+//
+//	type MessageSearch struct {
+//		Title       *string  `form:"title" filter:"title,op=regex"`
+//		CreatedFrom *int64   `form:"created_from" filter:"created_time,op=gte"`
+//		CreatedTo   *int64   `form:"created_to" filter:"created_time,op=lte"`
+//		IDs         []string `form:"ids" filter:"point_position_id"` // 默认为 in
+//	}
+func BuildFilter(search interface{}) (bson.M, error) {
+	filter, _, _, err := parseSearch(search)
+
+	return filter, err
+}
+
+// parseSearch 生成过滤条件, 并返回分页字段 Limit 与 Offset, 未设置时为 nil.
+func parseSearch(search interface{}) (filter bson.M, limit, offset *int64, err error) {
+	v := reflect.ValueOf(search)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return bson.M{}, nil, nil, nil
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, nil, nil, fmt.Errorf("search should be a struct, got %s", v.Kind())
+	}
+
+	conds := make(map[string]bson.M)
+	names := make([]string, 0)
+
+	add := func(name, op string, value interface{}) {
+		if _, ok := conds[name]; !ok {
+			conds[name] = bson.M{}
+			names = append(names, name)
+		}
+
+		conds[name][op] = value
+	}
+
+	var walk func(v reflect.Value) error
+	walk = func(v reflect.Value) error {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			rv := v.Field(i)
+
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				if err := walk(rv); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if !sf.IsExported() {
+				continue
+			}
+
+			// 排序参数由 Crud 单独处理
+			if sf.Name == "Sort" {
+				continue
+			}
+
+			if sf.Name == "Limit" || sf.Name == "Offset" {
+				if rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().CanInt() {
+					n := rv.Elem().Int()
+					if sf.Name == "Limit" {
+						limit = &n
+					} else {
+						offset = &n
+					}
+				}
+
+				continue
+			}
+
+			name, op, ok := filterTag(sf)
+			if !ok {
+				continue
+			}
+
+			value, ok := fieldValue(rv)
+			if !ok {
+				continue
+			}
+
+			isSlice := rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array
+			if op == "" {
+				op = FilterOpEq
+				if isSlice {
+					op = FilterOpIn
+				}
+			}
+
+			cond, err := filterCondition(op, value, isSlice)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", sf.Name, err)
+			}
+
+			for k, v := range cond {
+				add(name, k, v)
+			}
+		}
+
+		return nil
+	}
+
+	if err = walk(v); err != nil {
+		return nil, nil, nil, err
+	}
+
+	filter = make(bson.M, len(names))
+
+	for _, name := range names {
+		cond := conds[name]
+		if eq, ok := cond["$eq"]; ok && len(cond) == 1 {
+			filter[name] = eq
+			continue
+		}
+
+		filter[name] = cond
+	}
+
+	return filter, limit, offset, nil
+}
+
+// filterTag 返回字段名与操作符, 字段不参与过滤时 ok 为 false.
+func filterTag(sf reflect.StructField) (name, op string, ok bool) {
+	tag := sf.Tag.Get("filter")
+	if tag == "-" {
+		return "", "", false
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+
+	for _, part := range parts[1:] {
+		if strings.HasPrefix(part, "op=") {
+			op = strings.TrimPrefix(part, "op=")
+		}
+	}
+
+	if name == "" {
+		name = strings.Split(sf.Tag.Get("bson"), ",")[0]
+	}
+
+	if name == "-" {
+		return "", "", false
+	}
+
+	if name == "" {
+		name = sf.Name
+	}
+
+	return name, op, true
+}
+
+// fieldValue 返回指针指向的值或切片, nil 指针与空切片返回 false.
+func fieldValue(rv reflect.Value) (interface{}, bool) {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, false
+		}
+
+		return rv.Elem().Interface(), true
+	case reflect.Slice:
+		if rv.Len() == 0 {
+			return nil, false
+		}
+
+		return rv.Interface(), true
+	case reflect.Array:
+		return rv.Interface(), true
+	default:
+		return nil, false
+	}
+}
+
+// filterCondition 返回操作符对应的条件, 如 {"$gte": 1}.
+func filterCondition(op string, value interface{}, isSlice bool) (bson.M, error) {
+	mongoOp, ok := filterOperators[op]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter op %q", op)
+	}
+
+	if isSlice != (op == FilterOpIn || op == FilterOpNin) {
+		return nil, fmt.Errorf("filter op %q does not support this field type", op)
+	}
+
+	switch op {
+	case FilterOpRegex:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("filter op %q requires a string field", op)
+		}
+
+		return bson.M{mongoOp: primitive.Regex{Pattern: regexp.QuoteMeta(s), Options: "i"}}, nil
+	case FilterOpExists:
+		if _, ok := value.(bool); !ok {
+			return nil, fmt.Errorf("filter op %q requires a bool field", op)
+		}
+	}
+
+	return bson.M{mongoOp: value}, nil
+}
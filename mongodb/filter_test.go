@@ -0,0 +1,100 @@
+package mongodb
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type filterSearch struct {
+	Page
+
+	Name        *string  `form:"name" bson:"name"`
+	Title       *string  `form:"title" filter:"title,op=regex"`
+	CreatedFrom *int64   `form:"created_from" filter:"created_time,op=gte"`
+	CreatedTo   *int64   `form:"created_to" filter:"created_time,op=lte"`
+	IDs         []string `form:"ids" bson:"point_position_ids"`
+	ExcludeIDs  []string `form:"exclude_ids" filter:"point_position_ids,op=nin"`
+	HasURL      *bool    `form:"has_url" filter:"url,op=exists"`
+	Status      *int     `form:"status" filter:"status,op=ne"`
+	Ignored     *string  `filter:"-"`
+	Plain       string   `bson:"plain"`
+}
+
+func TestBuildFilter(t *testing.T) {
+	var (
+		name, title = "a", "x.y"
+		from, to    = int64(100), int64(200)
+		hasURL      = true
+		status      = 0
+		ignored     = "ignored"
+		limit, skip = int64(20), int64(40)
+		search      = &filterSearch{
+			Page:        Page{Limit: &limit, Offset: &skip},
+			Name:        &name,
+			Title:       &title,
+			CreatedFrom: &from,
+			CreatedTo:   &to,
+			IDs:         []string{"1", "2"},
+			HasURL:      &hasURL,
+			Status:      &status,
+			Ignored:     &ignored,
+			Plain:       "plain",
+		}
+	)
+
+	filter, gotLimit, gotOffset, err := parseSearch(search)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := bson.M{
+		"name":               "a",
+		"title":              bson.M{"$regex": primitive.Regex{Pattern: `x\.y`, Options: "i"}},
+		"created_time":       bson.M{"$gte": from, "$lte": to},
+		"point_position_ids": bson.M{"$in": []string{"1", "2"}},
+		"url":                bson.M{"$exists": true},
+		"status":             bson.M{"$ne": 0},
+	}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("got %v, want %v", filter, want)
+	}
+
+	if gotLimit == nil || *gotLimit != 20 || gotOffset == nil || *gotOffset != 40 {
+		t.Errorf("limit = %v, offset = %v", gotLimit, gotOffset)
+	}
+
+	// in 与 nin 合并到同一字段
+	filter, _ = BuildFilter(&filterSearch{IDs: []string{"1"}, ExcludeIDs: []string{"2"}})
+	want = bson.M{"point_position_ids": bson.M{"$in": []string{"1"}, "$nin": []string{"2"}}}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("got %v, want %v", filter, want)
+	}
+}
+
+func TestBuildFilter_InvalidOp(t *testing.T) {
+	v := "a"
+
+	cases := []interface{}{
+		&struct {
+			Name *string `filter:"name,op=like"`
+		}{&v},
+		&struct {
+			Name *string `filter:"name,op=in"`
+		}{&v},
+		&struct {
+			Names []string `filter:"name,op=gte"`
+		}{[]string{"a"}},
+		&struct {
+			Name *string `filter:"name,op=exists"`
+		}{&v},
+	}
+
+	for _, search := range cases {
+		if _, err := BuildFilter(search); err == nil {
+			t.Errorf("%+v: expect error", search)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package mongodb
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+// Health 所有集群的健康状态, 用于就绪探针.
+type Health struct {
+	Healthy  bool            `json:"healthy"` // 所有集群都有可写节点
+	Clusters []ClusterHealth `json:"clusters"`
+}
+
+// ClusterHealth 集群的健康状态.
+type ClusterHealth struct {
+	URI         string         `json:"uri"`     // 隐藏密码的集群地址
+	Healthy     bool           `json:"healthy"` // 有可写节点: standalone、副本集主节点或 mongos
+	Servers     []ServerHealth `json:"servers"`
+	UpdatedTime time.Time      `json:"updated_time"` // 最近一次拓扑变化的时间
+}
+
+// ServerHealth 节点状态.
+type ServerHealth struct {
+	Addr      string        `json:"addr"`
+	Kind      string        `json:"kind"`
+	RTT       time.Duration `json:"rtt"`
+	LastError string        `json:"last_error,omitempty"`
+}
+
+// clusterMonitor 通过 driver 的拓扑监控事件记录集群状态, driver 在后台定期心跳检查每个节点.
+type clusterMonitor struct {
+	lock   sync.RWMutex
+	health ClusterHealth
+}
+
+func newClusterMonitor(uri string) *clusterMonitor {
+	return &clusterMonitor{
+		health: ClusterHealth{URI: RedactURI(uri)},
+	}
+}
+
+func (c *clusterMonitor) serverMonitor() *event.ServerMonitor {
+	return &event.ServerMonitor{
+		TopologyDescriptionChanged: c.topologyChanged,
+	}
+}
+
+// topologyChanged 在 driver 持有拓扑锁时被调用, 不能执行需要选择节点的操作.
+func (c *clusterMonitor) topologyChanged(e *event.TopologyDescriptionChangedEvent) {
+	healthy := false
+	servers := make([]ServerHealth, 0, len(e.NewDescription.Servers))
+
+	for _, s := range e.NewDescription.Servers {
+		switch s.Kind {
+		case description.Standalone, description.RSPrimary, description.Mongos, description.LoadBalancer:
+			healthy = true
+		}
+
+		server := ServerHealth{
+			Addr: s.Addr.String(),
+			Kind: s.Kind.String(),
+			RTT:  s.AverageRTT,
+		}
+		if s.LastError != nil {
+			server.LastError = s.LastError.Error()
+		}
+
+		servers = append(servers, server)
+	}
+
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Addr < servers[j].Addr })
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.health.Healthy = healthy
+	c.health.Servers = servers
+	c.health.UpdatedTime = time.Now()
+}
+
+func (c *clusterMonitor) snapshot() ClusterHealth {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	health := c.health
+	health.Servers = append([]ServerHealth(nil), c.health.Servers...)
+
+	return health
+}
+
+// Health 返回所有集群的健康状态, 不会访问数据库.
+// 默认集群尚未连接时先建立连接, 连接建立并发现可写节点前返回不健康.
+func (m *MongoManager) Health() Health {
+	m.lock.Lock()
+	if m.conf.URI != "" {
+		_, _ = m.getClientLocked(m.conf.URI)
+	}
+
+	monitors := make([]*clusterMonitor, 0, len(m.monitors))
+	for _, monitor := range m.monitors {
+		monitors = append(monitors, monitor)
+	}
+	m.lock.Unlock()
+
+	health := Health{
+		Healthy:  len(monitors) > 0,
+		Clusters: make([]ClusterHealth, 0, len(monitors)),
+	}
+
+	for _, monitor := range monitors {
+		cluster := monitor.snapshot()
+		health.Healthy = health.Healthy && cluster.Healthy
+		health.Clusters = append(health.Clusters, cluster)
+	}
+
+	sort.Slice(health.Clusters, func(i, j int) bool { return health.Clusters[i].URI < health.Clusters[j].URI })
+
+	return health
+}
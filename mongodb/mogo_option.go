@@ -1,6 +1,10 @@
 package mongodb
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -8,10 +12,30 @@ import (
 
 var _ PageOrderIntfc = (*PageOrder)(nil)
 
+// DefaultCollation 排序时默认使用的排序规则, 为 nil 时不指定.
+var DefaultCollation = &options.Collation{Locale: "zh"}
+
+var ErrInvalidSort = errors.New("invalid sort")
+
 type PageOrderIntfc interface {
 	GetMongoFindOptions() *options.FindOptions
 }
 
+// findOptions 返回查询 option, 分页参数实现了 Validate 时先校验.
+func findOptions(opt PageOrderIntfc) (*options.FindOptions, error) {
+	if opt == nil {
+		return nil, nil
+	}
+
+	if v, ok := opt.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return opt.GetMongoFindOptions(), nil
+}
+
 // 分页参数.
 type Page struct {
 	Limit  *int64 `form:"limit,default=10" json:"limit" bson:"limit"`   // 每页大小, 默认10
@@ -36,16 +60,27 @@ func (p Page) GetMongoFindOptions() *options.FindOptions {
 type Order struct {
 	OrderBy string `form:"order_by" json:"order_by" bson:"order_by"` // 排序字段，默认 _id
 	Asc     bool   `form:"asc" json:"asc" bson:"asc"`                // true： 升序， false：降序, 默认降序
+	Sort    string `form:"-" json:"sort" bson:"sort"`                // 多字段排序, 如 -created_time,name, 优先于 OrderBy; 不从请求绑定, 由调用方按允许的字段校验后设置
 }
 
 // 分页与排序.
 // 分页传0是查询所有.
 type PageOrder struct {
 	Page
-	Order // TODO: 支持切片
+	Order
+
+	// 排序规则, 为 nil 时使用 DefaultCollation, Locale 为 simple 时按二进制比较.
+	Collation *options.Collation `form:"-" json:"-" bson:"-"`
+}
+
+// Validate 校验排序, Sort 格式错误时返回 ErrInvalidSort.
+func (p PageOrder) Validate() error {
+	_, err := ParseSort(p.Sort)
+
+	return err
 }
 
-// 分页函数返回 mongoDB 分页option, order排序.
+// 分页函数返回 mongoDB 分页option, order排序, Sort 格式错误时按 OrderBy 排序, 需要时先调用 Validate.
 func (p PageOrder) GetMongoFindOptions() *options.FindOptions {
 	opt := &options.FindOptions{}
 
@@ -62,21 +97,74 @@ func (p PageOrder) GetMongoFindOptions() *options.FindOptions {
 		p.OrderBy = defaultField
 	}
 
-	if p.Asc {
+	if sort, err := ParseSort(p.Sort); err == nil && len(sort) > 0 {
+		opt.Sort = sort
+	} else if p.Asc {
 		opt.Sort = bson.D{primitive.E{Key: p.OrderBy, Value: 1}}
-		opt.SetCollation(&options.Collation{
-			Locale: "zh",
-		})
 	} else {
 		opt.Sort = bson.D{primitive.E{Key: p.OrderBy, Value: -1}}
-		opt.SetCollation(&options.Collation{
-			Locale: "zh",
-		})
+	}
+
+	collation := p.Collation
+	if collation == nil {
+		collation = DefaultCollation
+	}
+
+	if collation != nil {
+		opt.SetCollation(collation)
 	}
 
 	return opt
 }
 
+// ParseSort 解析多字段排序, 字段以逗号分隔, - 开头为降序, 如 -created_time,name.
+// allowed 不为空时只允许其中的字段, 其他字段返回 ErrInvalidSort.
+func ParseSort(sort string, allowed ...string) (bson.D, error) {
+	sort = strings.TrimSpace(sort)
+	if sort == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(sort, ",")
+	result := make(bson.D, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+
+		order := 1
+		if strings.HasPrefix(field, "-") {
+			order = -1
+			field = field[1:]
+		} else {
+			field = strings.TrimPrefix(field, "+")
+		}
+
+		if field == "" || strings.HasPrefix(field, "$") || seen[field] {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSort, sort)
+		}
+
+		if len(allowed) > 0 && !contains(allowed, field) {
+			return nil, fmt.Errorf("%w: field %s is not sortable", ErrInvalidSort, field)
+		}
+
+		seen[field] = true
+		result = append(result, primitive.E{Key: field, Value: order})
+	}
+
+	return result, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
 func NewPageOrder(limit, offset int64) PageOrder {
 	return PageOrder{
 		Page: Page{
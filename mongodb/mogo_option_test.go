@@ -0,0 +1,88 @@
+package mongodb
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestParseSort(t *testing.T) {
+	got, err := ParseSort("-created_time, name,+age")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := bson.D{{Key: "created_time", Value: -1}, {Key: "name", Value: 1}, {Key: "age", Value: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, sort := range []string{"name,", "-", "$where", "name,-name"} {
+		if _, err = ParseSort(sort); !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("%q: err = %v, want ErrInvalidSort", sort, err)
+		}
+	}
+
+	if _, err = ParseSort("-created_time,password", "created_time", "name"); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("err = %v, want ErrInvalidSort", err)
+	}
+}
+
+func TestPageOrder_Sort(t *testing.T) {
+	p := NewPageOrder(10, 0)
+	p.Sort = "-created_time,name"
+
+	opt := p.GetMongoFindOptions()
+	want := bson.D{{Key: "created_time", Value: -1}, {Key: "name", Value: 1}}
+	if !reflect.DeepEqual(opt.Sort, want) {
+		t.Errorf("sort = %v, want %v", opt.Sort, want)
+	}
+	if opt.Collation != DefaultCollation {
+		t.Errorf("collation = %v, want default", opt.Collation)
+	}
+
+	// 兼容单字段排序
+	p = NewPageOrder(10, 0)
+	p.OrderBy, p.Asc = "name", true
+	p.Collation = &options.Collation{Locale: "simple"}
+
+	opt = p.GetMongoFindOptions()
+	if want = (bson.D{{Key: "name", Value: 1}}); !reflect.DeepEqual(opt.Sort, want) {
+		t.Errorf("sort = %v, want %v", opt.Sort, want)
+	}
+	if opt.Collation.Locale != "simple" {
+		t.Errorf("collation = %v, want simple", opt.Collation)
+	}
+
+	// 格式错误的排序在查询前返回错误
+	p.Sort = "-"
+	if err := p.Validate(); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("err = %v, want ErrInvalidSort", err)
+	}
+	if _, err := findOptions(p); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("err = %v, want ErrInvalidSort", err)
+	}
+	if _, err := findOptions(Page{}); err != nil {
+		t.Errorf("page without Validate should not fail, err = %v", err)
+	}
+}
+
+func TestPageOrder_BindQuery(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/?order_by=name&asc=true&sort=-password", nil)
+
+	var p PageOrder
+	if err := c.ShouldBindQuery(&p); err != nil {
+		t.Fatal(err)
+	}
+
+	// sort 需要按允许的字段校验, 不能直接从请求绑定
+	if p.OrderBy != "name" || !p.Asc || p.Sort != "" {
+		t.Errorf("unexpected binding: %+v", p.Order)
+	}
+}
@@ -1,13 +1,13 @@
 package mongodb
 
 import (
+	"container/list"
 	"context"
 	"fmt"
 	"sync"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 var (
@@ -21,88 +21,132 @@ func InitMongoManager(conf MongoConf) {
 	})
 }
 
-type MongoConf struct {
-	URI      string `json:"-"`                          // mongo server uri
-	Addr     string `json:"addr" yaml:"ADDR"`           // ip:port
-	Database string `json:"-"`                          // 数据库名称
-	UserName string `json:"user_name" yaml:"USER_NAME"` // 用户名
-	PWD      string `json:"pwd" yaml:"PWD"`             // 密码
-}
-
 // MongoConnMgr mongodb连接管理.
 // 多租户下(目前是数据隔离), 不同租户连接的数据库不同.
+// 同一集群的所有租户共用一个 mongo.Client 及其连接池, 连接数不随租户数量增加;
+// 租户的数据库按 LRU 缓存, 最多缓存 MaxTenants 个.
 type MongoManager struct {
-	mongoClients sync.Map // FIXME: 租户数量不断增加，Map的数量也不断增加
-	lock         sync.RWMutex
-	conf         MongoConf
+	lock     sync.Mutex
+	conf     MongoConf
+	clients  map[string]*mongo.Client // 集群uri -> client
+	monitors map[string]*clusterMonitor
+	tenants  *list.List // 租户数据库, 队首为最近使用
+	index    map[tenantKey]*list.Element
+}
+
+type tenantKey struct {
+	uri    string
+	dbName string
+}
+
+type tenantDB struct {
+	key tenantKey
+	db  *mongo.Database
 }
 
 func NewMongoManager(conf MongoConf) *MongoManager {
+	if conf.URI == "" && conf.Addr != "" {
+		conf.URI = conf.GetURI()
+	}
+
+	if conf.MaxTenants <= 0 {
+		conf.MaxTenants = defaultMaxTenants
+	}
+
 	return &MongoManager{
-		conf: conf,
+		conf:     conf,
+		clients:  make(map[string]*mongo.Client),
+		monitors: make(map[string]*clusterMonitor),
+		tenants:  list.New(),
+		index:    make(map[tenantKey]*list.Element),
 	}
 }
 
-// 所有租户同一把锁.
+// GetDB 返回默认集群上的数据库.
 // 数据库名称一般为租户ID
 func (m *MongoManager) GetDB(dbName string) (*mongo.Database, error) {
-	client, ok := m.mongoClients.Load(dbName)
-	if !ok {
-		m.lock.Lock()
-		defer m.lock.Unlock()
-
-		client, ok = m.mongoClients.Load(dbName)
-		if ok {
-			c, ok := client.(*mongo.Database)
-			if !ok {
-				return nil, fmt.Errorf("client convert to mongo.Database failed")
-			}
-
-			return c, nil
-		}
+	return m.GetClusterDB(m.conf.URI, dbName)
+}
 
-		newClient, err := m.newDB(dbName)
-		if err != nil {
-			return nil, err
-		}
+// GetClusterDB 返回 uri 对应集群上的数据库, 用于租户分布在多个集群的场景.
+// 从 LRU 缓存中查找租户数据库, 不存在时使用集群的 client 创建, 不访问数据库;
+// 集群状态由后台监控, 通过 Health 查看.
+func (m *MongoManager) GetClusterDB(uri, dbName string) (*mongo.Database, error) {
+	key := tenantKey{uri: uri, dbName: dbName}
 
-		m.mongoClients.Store(dbName, newClient)
+	m.lock.Lock()
+	defer m.lock.Unlock()
 
-		return newClient, nil
-	}
+	if e, ok := m.index[key]; ok {
+		m.tenants.MoveToFront(e)
 
-	// 每次获取客户端时ping一次连接是否正常, 如果不正常则删除当前k,v, 然后重新设置并返回新的连接
-	if err := client.(*mongo.Database).Client().Ping(context.TODO(), readpref.Primary()); err != nil {
-		m.mongoClients.Delete(dbName)
+		return e.Value.(*tenantDB).db, nil
+	}
 
-		newClient, err := m.newDB(dbName)
-		if err != nil {
-			return nil, err
-		}
+	client, err := m.getClientLocked(uri)
+	if err != nil {
+		return nil, err
+	}
 
-		m.mongoClients.Store(dbName, newClient)
+	db := client.Database(dbName)
+	m.index[key] = m.tenants.PushFront(&tenantDB{key: key, db: db})
 
-		return newClient, nil
+	for m.tenants.Len() > m.conf.MaxTenants {
+		oldest := m.tenants.Back()
+		m.tenants.Remove(oldest)
+		delete(m.index, oldest.Value.(*tenantDB).key)
 	}
 
-	return client.(*mongo.Database), nil
+	return db, nil
 }
 
-func (m *MongoManager) newDB(tenantID string) (*mongo.Database, error) {
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(m.conf.URI))
+// getClientLocked 返回集群的 client, 不存在时创建. mongo.Connect 不会等待连接建立.
+func (m *MongoManager) getClientLocked(uri string) (*mongo.Client, error) {
+	if client, ok := m.clients[uri]; ok {
+		return client, nil
+	}
+
+	opts, err := m.conf.clientOptions(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	monitor := newClusterMonitor(uri)
+	opts.SetServerMonitor(monitor.serverMonitor())
+
+	client, err := mongo.Connect(context.TODO(), opts)
 	if err != nil {
 		fmt.Println("构建数据库client异常: ", err)
 
 		return nil, err
 	}
 
-	if err = client.Ping(context.TODO(), readpref.Primary()); err != nil {
-		fmt.Println("ping数据库异常: ", err)
+	m.clients[uri] = client
+	m.monitors[uri] = monitor
 
-		return nil, err
+	return client, nil
+}
+
+// Close 断开所有集群的连接.
+func (m *MongoManager) Close(ctx context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var firstErr error
+
+	for uri, client := range m.clients {
+		if err := client.Disconnect(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		delete(m.clients, uri)
+		delete(m.monitors, uri)
 	}
 
-	return client.Database(tenantID), nil
+	m.tenants.Init()
+	m.index = make(map[tenantKey]*list.Element)
+
+	return firstErr
 }
 
 type Collection interface {
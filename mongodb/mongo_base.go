@@ -15,6 +15,8 @@ type BaseInterfc interface {
 	Create(ctx context.Context, data interface{}) (string, error)
 	CreateMany(ctx context.Context, documents []interface{}) ([]string, error)       // 批量创建, 返回id列表
 	DeleteFalseByIds(ctx context.Context, ids []string) (int64, error)               // 假删除
+	SoftDeleteByIds(ctx context.Context, ids []string, user string) (int64, error)   // 假删除, 记录删除人
+	Restore(ctx context.Context, ids []string) (int64, error)                        // 恢复假删除
 	DeleteByIds(ctx context.Context, ids []string) (int64, error)                    // 真删除
 	DeleteByIdsOrgId(ctx context.Context, ids []string, orgId string) (int64, error) // 真删除
 	DeleteByFilter(ctx context.Context, filter interface{}) (int64, error)
@@ -22,10 +24,13 @@ type BaseInterfc interface {
 	UpdateOneByFilter(ctx context.Context, filter interface{}, data interface{}) error
 	UpdateMany(ctx context.Context, filter interface{}, data interface{}) error
 	ReplaceOneById(ctx context.Context, id string, data interface{}) error
+	UpdateByIDWithVersion(ctx context.Context, id string, version int64, data interface{}) error // 乐观锁, 版本号不一致返回 ConflictError
 	Upsert(ctx context.Context, filter interface{}, data interface{}) (string, error)
 	Find(ctx context.Context, filter interface{}, opt PageOrderIntfc, results interface{}) error
+	FindWithDeleted(ctx context.Context, filter interface{}, opt PageOrderIntfc, results interface{}) error
 	FindWithPage(ctx context.Context, filter interface{}, pageOrder PageOrderIntfc, results interface{}) (int64, error)
 	FindByNameWithPage(ctx context.Context, name string, pageOrder PageOrderIntfc, results interface{}) (int64, error)
+	FindWithCursor(ctx context.Context, filter interface{}, q CursorQuery, results interface{}) (string, error) // 游标分页, 返回下一页游标
 	FindOne(ctx context.Context, filter interface{}, result interface{}) error
 	FindOneByID(ctx context.Context, id string, result interface{}) error
 	IsExist(ctx context.Context, filter interface{}) (bool, error)
@@ -35,13 +40,20 @@ type BaseInterfc interface {
 
 type Base struct {
 	collection Collection
+	softDelete bool // 查询时排除假删除的文档
+	versioned  bool // 写入时维护版本号, 见 WithVersion
 }
 
 func NewBase(collection Collection) *Base {
-	return &Base{collection}
+	return &Base{collection: collection}
 }
 
 func (b *Base) Create(ctx context.Context, data interface{}) (string, error) {
+	data, err := b.stampVersion(data)
+	if err != nil {
+		return "", err
+	}
+
 	ctx, cancel := NewContextWithTimeout(ctx)
 	defer cancel()
 
@@ -58,28 +70,9 @@ func (b *Base) Create(ctx context.Context, data interface{}) (string, error) {
 	return objID.Hex(), nil
 }
 
-// 假删除.
+// 假删除, 不记录删除人.
 func (b *Base) DeleteFalseByIds(ctx context.Context, ids []string) (int64, error) {
-	objIDs := b.getObjecIds(ids)
-
-	filter := bson.M{
-		"_id": bson.M{
-			"$in": objIDs,
-		},
-	}
-
-	update := bson.M{
-		"$set": bson.M{
-			"is_delete": true,
-		},
-	}
-
-	ctx, cancel := NewContextWithTimeout(ctx)
-	defer cancel()
-
-	result, err := b.collection.UpdateMany(ctx, filter, update)
-
-	return result.MatchedCount, err
+	return b.SoftDeleteByIds(ctx, ids, "")
 }
 
 // 真删除.
@@ -100,7 +93,7 @@ func (b *Base) DeleteByIds(ctx context.Context, ids []string) (int64, error) {
 	return result.DeletedCount, err
 }
 
-// 真删除.
+// 真删除, 只删除属于租户 orgId 的文档.
 func (b *Base) DeleteByIdsOrgId(ctx context.Context, ids []string, orgId string) (int64, error) {
 	objIDs := b.getObjecIds(ids)
 
@@ -108,6 +101,7 @@ func (b *Base) DeleteByIdsOrgId(ctx context.Context, ids []string, orgId string)
 		"_id": bson.M{
 			"$in": objIDs,
 		},
+		FieldOrgID: orgId,
 	}
 
 	ctx, cancel := NewContextWithTimeout(ctx)
@@ -135,40 +129,71 @@ func (b *Base) UpdateByID(ctx context.Context, id string, data interface{}) erro
 		return err
 	}
 
-	ctx, cancel := NewContextWithTimeout(ctx)
-	defer cancel()
-
-	update := bson.M{
-		"$set": data,
-	}
-	_, err = b.collection.UpdateByID(ctx, objID, update)
+	_, err = b.updateOne(ctx, bson.M{"_id": objID}, data)
 
 	return err
 }
 
 func (b *Base) UpdateOneByFilter(ctx context.Context, filter interface{}, data interface{}) error {
+	_, err := b.updateOne(ctx, filter, data)
+
+	return err
+}
+
+// updateOne 以 $set 更新一条未删除的文档, 开启版本号时版本号加1.
+func (b *Base) updateOne(ctx context.Context, filter interface{}, data interface{}) (*mongo.UpdateResult, error) {
+	update, err := b.setUpdate(data)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := NewContextWithTimeout(ctx)
 	defer cancel()
 
-	update := bson.M{
-		"$set": data,
+	return b.collection.UpdateOne(ctx, b.notDeleted(filter), update)
+}
+
+// findOneAndUpdate 以 $set 更新一条未删除的文档并将更新后的文档解码到 result, 不存在时返回 ErrNotFound.
+func (b *Base) findOneAndUpdate(ctx context.Context, filter interface{}, data interface{}, result interface{}) error {
+	update, err := b.setUpdate(data)
+	if err != nil {
+		return err
 	}
 
-	// _, err = b.collection.UpdateByID(ctx, objID, update)
-	_, err := b.collection.UpdateOne(ctx, filter, update, nil)
+	ctx, cancel := NewContextWithTimeout(ctx)
+	defer cancel()
 
-	return err
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	mongoResult := b.collection.FindOneAndUpdate(ctx, b.notDeleted(filter), update, opt)
+	if err := mongoResult.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	return mongoResult.Decode(result)
 }
 
 func (b *Base) UpdateMany(ctx context.Context, filter interface{}, data interface{}) error {
-	_, err := b.collection.UpdateMany(ctx, filter, data, nil)
+	data, err := b.incVersion(data)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := NewContextWithTimeout(ctx)
+	defer cancel()
+
+	_, err = b.collection.UpdateMany(ctx, b.notDeleted(filter), data)
 
 	return err
 }
 
 // 替换文档,也相当于更新文档
 //
-// data为model.
+// data为model, 开启版本号时 data 中的版本号与数据库不一致返回 ConflictError.
 func (b *Base) ReplaceOneById(ctx context.Context, id string, data interface{}) error {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -179,26 +204,45 @@ func (b *Base) ReplaceOneById(ctx context.Context, id string, data interface{})
 		"_id": objID,
 	}
 
-	ctx, cancel := NewContextWithTimeout(ctx)
-	defer cancel()
-
-	_, err = b.collection.ReplaceOne(ctx, filter, data)
-
-	return err
+	return b.replaceOne(ctx, filter, data)
 }
 
 // 存在则更新， 不存在则添加.
+//
+// 开启版本号时以 data 中的版本号作为期望的版本号, 写入的版本号加1, 新增的文档版本号为1;
+// filter 需要匹配唯一索引, 文档已存在且版本号不一致时返回 ConflictError.
 func (b *Base) Upsert(ctx context.Context, filter interface{}, data interface{}) (string, error) {
 	ctx, cancel := NewContextWithTimeout(ctx)
 	defer cancel()
 
+	cond := b.notDeleted(filter)
+
+	var version int64
+
+	if b.versioned {
+		var err error
+		if cond, data, version, err = b.expectVersion(filter, data); err != nil {
+			return "", err
+		}
+	}
+
 	opt := options.Replace().SetUpsert(true)
 
-	mongoResult, err := b.collection.ReplaceOne(ctx, filter, data, opt)
+	mongoResult, err := b.collection.ReplaceOne(ctx, cond, data, opt)
 	if err != nil {
+		// 版本号不一致时没有匹配的文档, 插入新文档违反唯一索引
+		if b.versioned && mongo.IsDuplicateKeyError(err) {
+			return "", b.conflict(ctx, filter, version)
+		}
+
 		return "", err
 	}
 
+	// 更新已有文档时 UpsertedID 为 nil
+	if mongoResult.MatchedCount > 0 {
+		return b.matchedID(ctx, filter, data)
+	}
+
 	objID, ok := mongoResult.UpsertedID.(primitive.ObjectID)
 	if !ok {
 		return "", errors.New("id error")
@@ -207,11 +251,52 @@ func (b *Base) Upsert(ctx context.Context, filter interface{}, data interface{})
 	return objID.Hex(), nil
 }
 
+// matchedID 返回 Upsert 更新的已有文档的主键, 依次从 data、filter 中读取, 都没有时查询.
+func (b *Base) matchedID(ctx context.Context, filter interface{}, data interface{}) (string, error) {
+	for _, doc := range []interface{}{data, filter} {
+		if objID, ok := objectIDOf(doc); ok {
+			return objID.Hex(), nil
+		}
+	}
+
+	var result struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+
+	opt := options.FindOne().SetProjection(bson.M{"_id": 1})
+
+	if err := b.collection.FindOne(ctx, b.notDeleted(filter), opt).Decode(&result); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", ErrNotFound
+		}
+
+		return "", err
+	}
+
+	return result.ID.Hex(), nil
+}
+
+// objectIDOf 返回文档中类型为 ObjectID 的 _id, 零值视为没有.
+func objectIDOf(doc interface{}) (primitive.ObjectID, bool) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return primitive.NilObjectID, false
+	}
+
+	objID, ok := bson.Raw(raw).Lookup("_id").ObjectIDOK()
+
+	return objID, ok && !objID.IsZero()
+}
+
 // 查询过滤, 返回所有结果，不分页.
 func (b *Base) Find(ctx context.Context, filter interface{}, opt PageOrderIntfc, results interface{}) error {
-	var findOpt *options.FindOptions
-	if opt != nil {
-		findOpt = opt.GetMongoFindOptions()
+	return b.find(ctx, b.notDeleted(filter), opt, results)
+}
+
+func (b *Base) find(ctx context.Context, filter interface{}, opt PageOrderIntfc, results interface{}) error {
+	findOpt, err := findOptions(opt)
+	if err != nil {
+		return err
 	}
 
 	ctx, cancel := NewContextWithTimeout(ctx)
@@ -231,11 +316,13 @@ func (b *Base) FindWithPage(ctx context.Context, filter interface{}, pageOrder P
 	ctx, cancel := NewContextWithTimeout(ctx)
 	defer cancel()
 
-	var findOpt *options.FindOptions
-	if pageOrder != nil {
-		findOpt = pageOrder.GetMongoFindOptions()
+	findOpt, err := findOptions(pageOrder)
+	if err != nil {
+		return 0, err
 	}
 
+	filter = b.notDeleted(filter)
+
 	totalCount, err := b.collection.CountDocuments(ctx, filter)
 	if err != nil {
 		return 0, err
@@ -274,7 +361,7 @@ func (b *Base) FindOne(ctx context.Context, filter interface{}, result interface
 	ctx, cancel := NewContextWithTimeout(ctx)
 	defer cancel()
 
-	mongoResult := b.collection.FindOne(ctx, filter)
+	mongoResult := b.collection.FindOne(ctx, b.notDeleted(filter))
 	if mongoResult.Err() != nil {
 		if errors.Is(mongoResult.Err(), mongo.ErrNoDocuments) {
 			return ErrNotFound
@@ -304,7 +391,7 @@ func (b *Base) IsExist(ctx context.Context, filter interface{}) (bool, error) {
 	ctx, cancel := NewContextWithTimeout(ctx)
 	defer cancel()
 
-	totalCount, err := b.collection.CountDocuments(ctx, filter)
+	totalCount, err := b.collection.CountDocuments(ctx, b.notDeleted(filter))
 	if err != nil {
 		return false, err
 	}
@@ -321,7 +408,7 @@ func (b *Base) IsExistByName(ctx context.Context, name string) (bool, error) {
 }
 
 func (b *Base) Count(ctx context.Context, filter interface{}) (int64, error) {
-	return b.collection.CountDocuments(ctx, filter)
+	return b.collection.CountDocuments(ctx, b.notDeleted(filter))
 }
 
 func (b *Base) getObjecIds(ids []string) []primitive.ObjectID {
@@ -341,6 +428,21 @@ func (b *Base) getObjecIds(ids []string) []primitive.ObjectID {
 
 // 批量创建, 返回  primitive.ObjectID 列表.
 func (b *Base) CreateMany(ctx context.Context, documents []interface{}) ([]string, error) {
+	if b.versioned {
+		docs := make([]interface{}, 0, len(documents))
+
+		for _, document := range documents {
+			doc, err := b.stampVersion(document)
+			if err != nil {
+				return nil, err
+			}
+
+			docs = append(docs, doc)
+		}
+
+		documents = docs
+	}
+
 	result, err := b.collection.InsertMany(ctx, documents)
 	if err != nil {
 		return nil, err
@@ -358,6 +460,63 @@ func (b *Base) CreateMany(ctx context.Context, documents []interface{}) ([]strin
 	return ids, err
 }
 
+// hasField 过滤条件的顶层是否包含 key.
+func hasField(filter interface{}, key string) bool {
+	switch f := filter.(type) {
+	case bson.M:
+		_, ok := f[key]
+		return ok
+	case map[string]interface{}:
+		_, ok := f[key]
+		return ok
+	case bson.D:
+		for _, e := range f {
+			if e.Key == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// addCondition 在过滤条件中增加 key 的条件, 不修改原过滤条件.
+// 过滤条件已包含 key 或不是 bson.M/bson.D 时使用 $and 组合.
+func addCondition(filter interface{}, key string, cond interface{}) interface{} {
+	if filter == nil {
+		return bson.M{key: cond}
+	}
+
+	if hasField(filter, key) {
+		return bson.M{"$and": bson.A{filter, bson.M{key: cond}}}
+	}
+
+	switch f := filter.(type) {
+	case bson.M:
+		return withField(f, key, cond)
+	case map[string]interface{}:
+		return withField(f, key, cond)
+	case bson.D:
+		d := make(bson.D, 0, len(f)+1)
+		d = append(d, f...)
+
+		return append(d, bson.E{Key: key, Value: cond})
+	default:
+		return bson.M{"$and": bson.A{filter, bson.M{key: cond}}}
+	}
+}
+
+func withField(filter map[string]interface{}, key string, cond interface{}) bson.M {
+	m := make(bson.M, len(filter)+1)
+	for k, v := range filter {
+		m[k] = v
+	}
+
+	m[key] = cond
+
+	return m
+}
+
 const ( // timeout.
 	DefaultTimeout = time.Second * 10
 )
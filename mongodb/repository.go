@@ -0,0 +1,234 @@
+package mongodb
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PageResult 分页查询结果, 与列表接口的返回格式一致.
+type PageResult[T any] struct {
+	Data       []T   `json:"data"`
+	TotalCount int64 `json:"total_count"`
+}
+
+// Repository 类型安全的集合操作, 封装 Collection, 查询结果直接解码为 T.
+// T 为 model 结构体或其指针, 主键为 bson tag 为 _id 的 primitive.ObjectID 字段.
+//
+//	users := mongodb.NewRepository[model.User](db.Collection("user"))
+//	user, err := users.FindByID(ctx, id)
+type Repository[T any] struct {
+	base *Base
+}
+
+func NewRepository[T any](collection Collection) *Repository[T] {
+	return &Repository[T]{
+		base: NewBase(collection),
+	}
+}
+
+// Base 返回非泛型的 Base, 用于 Repository 未提供的操作.
+func (r *Repository[T]) Base() *Base {
+	return r.base
+}
+
+// Insert 插入文档, 主键为空时自动生成, 返回带主键的文档.
+func (r *Repository[T]) Insert(ctx context.Context, doc T) (T, error) {
+	doc = withObjectID(doc)
+
+	if _, err := r.base.Create(ctx, doc); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return doc, nil
+}
+
+// InsertMany 批量插入文档, 主键为空时自动生成, 返回带主键的文档.
+func (r *Repository[T]) InsertMany(ctx context.Context, docs []T) ([]T, error) {
+	if len(docs) == 0 {
+		return []T{}, nil
+	}
+
+	documents := make([]interface{}, 0, len(docs))
+	results := make([]T, 0, len(docs))
+
+	for _, doc := range docs {
+		doc = withObjectID(doc)
+		documents = append(documents, doc)
+		results = append(results, doc)
+	}
+
+	if _, err := r.base.CreateMany(ctx, documents); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// FindByID 根据主键查询, 不存在时返回 ErrNotFound.
+func (r *Repository[T]) FindByID(ctx context.Context, id string) (T, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return r.FindOne(ctx, bson.M{"_id": objID})
+}
+
+// FindOne 返回第一条结果, 不存在时返回 ErrNotFound.
+func (r *Repository[T]) FindOne(ctx context.Context, filter interface{}) (T, error) {
+	result := newDoc[T]()
+
+	err := r.base.FindOne(ctx, filter, result)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return *result, nil
+}
+
+// Find 查询过滤, opt 为 nil 时返回所有结果, 没有结果时返回空切片.
+func (r *Repository[T]) Find(ctx context.Context, filter interface{}, opt PageOrderIntfc) ([]T, error) {
+	results := make([]T, 0)
+
+	if err := r.base.Find(ctx, filter, opt, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Page 分页查询, 返回当前页的结果与总记录个数.
+func (r *Repository[T]) Page(ctx context.Context, filter interface{}, pageOrder PageOrderIntfc) (PageResult[T], error) {
+	page := PageResult[T]{Data: make([]T, 0)}
+
+	totalCount, err := r.base.FindWithPage(ctx, filter, pageOrder, &page.Data)
+	if err != nil {
+		return PageResult[T]{}, err
+	}
+
+	page.TotalCount = totalCount
+
+	return page, nil
+}
+
+// UpdateByID 根据主键更新, data 为 model 或 bson.M, 不存在时返回 ErrNotFound.
+func (r *Repository[T]) UpdateByID(ctx context.Context, id string, data interface{}) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.base.updateOne(ctx, bson.M{"_id": objID}, data)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// FindOneAndUpdate 根据过滤条件更新并返回更新后的文档, 不存在时返回 ErrNotFound.
+func (r *Repository[T]) FindOneAndUpdate(ctx context.Context, filter interface{}, data interface{}) (T, error) {
+	var zero T
+
+	result := newDoc[T]()
+	if err := r.base.findOneAndUpdate(ctx, filter, data, result); err != nil {
+		return zero, err
+	}
+
+	return *result, nil
+}
+
+// DeleteByID 根据主键删除, 不存在时返回 ErrNotFound.
+func (r *Repository[T]) DeleteByID(ctx context.Context, id string) error {
+	count, err := r.base.DeleteByIds(ctx, []string{id})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository[T]) Count(ctx context.Context, filter interface{}) (int64, error) {
+	return r.base.Count(ctx, filter)
+}
+
+// newDoc 返回用于解码的 *T, T 为指针时同时分配指向的结构体.
+func newDoc[T any]() *T {
+	result := new(T)
+
+	v := reflect.ValueOf(result).Elem()
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+
+	return result
+}
+
+// withObjectID 主键为空时为文档生成主键, T 为结构体时返回修改后的副本.
+func withObjectID[T any](doc T) T {
+	v := reflect.ValueOf(&doc).Elem()
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return doc
+		}
+
+		v = v.Elem()
+	}
+
+	if field, ok := objectIDField(v); ok && field.Interface().(primitive.ObjectID).IsZero() {
+		field.Set(reflect.ValueOf(primitive.NewObjectID()))
+	}
+
+	return doc
+}
+
+var objectIDType = reflect.TypeOf(primitive.ObjectID{})
+
+// objectIDField 查找 bson tag 为 _id 的 ObjectID 字段, 支持 inline 嵌入的结构体.
+func objectIDField(v reflect.Value) (reflect.Value, bool) {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		tag := sf.Tag.Get("bson")
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if name == "_id" {
+			if sf.Type == objectIDType {
+				return v.Field(i), true
+			}
+
+			return reflect.Value{}, false
+		}
+
+		if sf.Type.Kind() == reflect.Struct && strings.Contains(opts, "inline") {
+			if field, ok := objectIDField(v.Field(i)); ok {
+				return field, true
+			}
+		}
+	}
+
+	return reflect.Value{}, false
+}
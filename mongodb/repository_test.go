@@ -0,0 +1,172 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RepoBase struct {
+	ID   primitive.ObjectID `bson:"_id"`
+	Name string             `bson:"name"`
+}
+
+type repoDoc struct {
+	RepoBase `bson:",inline"`
+	Value    int `bson:"value"`
+}
+
+func TestWithObjectID(t *testing.T) {
+	doc := withObjectID(repoDoc{})
+	if doc.ID.IsZero() {
+		t.Fatal("inline _id should be generated")
+	}
+
+	ptr := withObjectID(&RepoBase{})
+	if ptr.ID.IsZero() {
+		t.Fatal("_id of pointer document should be generated")
+	}
+
+	id := primitive.NewObjectID()
+	if got := withObjectID(RepoBase{ID: id}); got.ID != id {
+		t.Errorf("existing _id should be kept, got %s", got.ID.Hex())
+	}
+
+	// 没有 _id 字段或为 nil 时不修改
+	_ = withObjectID(map[string]interface{}{})
+	_ = withObjectID[*RepoBase](nil)
+}
+
+func TestNewDoc(t *testing.T) {
+	if p := newDoc[*RepoBase](); *p == nil {
+		t.Fatal("pointer type should be allocated for decoding")
+	}
+
+	if p := newDoc[RepoBase](); p == nil {
+		t.Fatal("newDoc should not return nil")
+	}
+}
+
+// recordCollection 记录最后一次写操作的过滤条件与更新, 不需要 mongo 服务.
+type recordCollection struct {
+	Collection
+	filter   interface{}
+	update   interface{}
+	calls    []recordCall // 所有写操作, 按调用顺序
+	deadline bool
+	matched  int64
+	err      error       // ReplaceOne 返回的错误
+	doc      interface{} // FindOne 与 FindOneAndUpdate 返回的文档, 为 nil 时返回 ErrNoDocuments
+}
+
+type recordCall struct {
+	filter interface{}
+	update interface{}
+}
+
+func (c *recordCollection) record(ctx context.Context, filter, update interface{}) {
+	_, c.deadline = ctx.Deadline()
+	c.filter = filter
+	c.update = update
+	c.calls = append(c.calls, recordCall{filter: filter, update: update})
+}
+
+func (c *recordCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{},
+	_ ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	c.record(ctx, filter, update)
+
+	return &mongo.UpdateResult{MatchedCount: c.matched}, nil
+}
+
+func (c *recordCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{},
+	_ ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	c.record(ctx, filter, update)
+
+	return &mongo.UpdateResult{MatchedCount: c.matched, ModifiedCount: c.matched}, nil
+}
+
+func (c *recordCollection) ReplaceOne(ctx context.Context, filter interface{}, replacement interface{},
+	_ ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	c.record(ctx, filter, replacement)
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	// 与 driver 一致, 只有插入新文档时返回 UpsertedID
+	if c.matched > 0 {
+		return &mongo.UpdateResult{MatchedCount: c.matched}, nil
+	}
+
+	return &mongo.UpdateResult{UpsertedID: primitive.NewObjectID()}, nil
+}
+
+func (c *recordCollection) DeleteMany(ctx context.Context, filter interface{},
+	_ ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	c.record(ctx, filter, nil)
+
+	return &mongo.DeleteResult{DeletedCount: c.matched}, nil
+}
+
+func (c *recordCollection) FindOne(_ context.Context, _ interface{},
+	_ ...*options.FindOneOptions) *mongo.SingleResult {
+	return c.result()
+}
+
+func (c *recordCollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{},
+	_ ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+	c.record(ctx, filter, update)
+
+	return c.result()
+}
+
+func (c *recordCollection) result() *mongo.SingleResult {
+	if c.doc == nil {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+
+	return mongo.NewSingleResultFromDocument(c.doc, nil, nil)
+}
+
+func TestRepository_Update(t *testing.T) {
+	c := &recordCollection{}
+	repo := NewRepository[RepoBase](c)
+	id := primitive.NewObjectID()
+
+	if err := repo.UpdateByID(context.Background(), id.Hex(), bson.M{"name": "a"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing document should return ErrNotFound, got %v", err)
+	}
+
+	if !c.deadline {
+		t.Error("update should use a context with timeout")
+	}
+
+	if want := (bson.M{"_id": id}); !reflect.DeepEqual(c.filter, want) {
+		t.Errorf("filter = %v, want %v", c.filter, want)
+	}
+
+	if want := (bson.M{"$set": bson.M{"name": "a"}}); !reflect.DeepEqual(c.update, want) {
+		t.Errorf("update = %v, want %v", c.update, want)
+	}
+
+	if _, err := repo.FindOneAndUpdate(context.Background(), bson.M{"name": "a"}, bson.M{"name": "b"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing document should return ErrNotFound, got %v", err)
+	}
+
+	c.doc = RepoBase{ID: id, Name: "b"}
+
+	got, err := repo.FindOneAndUpdate(context.Background(), bson.M{"name": "a"}, bson.M{"name": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.ID != id || got.Name != "b" || !c.deadline {
+		t.Errorf("updated document should be decoded, got %+v", got)
+	}
+}
@@ -0,0 +1,139 @@
+package mongodb
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultTenantHeader = "X-Tenant-ID"
+
+	maxDatabaseNameLen = 63
+)
+
+var (
+	ErrTenantNotResolved = errors.New("tenant not resolved from request")
+	ErrTenantNotAllowed  = errors.New("tenant is not allowed")
+	ErrInvalidTenant     = errors.New("invalid tenant database name")
+	ErrMongoNotInit      = errors.New("mongo manager is not initialized")
+)
+
+// TenantResolver 根据请求返回租户的数据库名称.
+// 无法得到租户时返回 ErrTenantNotResolved.
+type TenantResolver interface {
+	Resolve(c *gin.Context) (string, error)
+}
+
+type TenantResolverFunc func(c *gin.Context) (string, error)
+
+func (f TenantResolverFunc) Resolve(c *gin.Context) (string, error) {
+	return f(c)
+}
+
+// ContextTenantResolver 从 gin 上下文中获取租户, key 为空时使用 org_id, 一般由认证中间件设置.
+func ContextTenantResolver(key string) TenantResolver {
+	if key == "" {
+		key = CtxOrgID
+	}
+
+	return TenantResolverFunc(func(c *gin.Context) (string, error) {
+		return resolved(c.GetString(key))
+	})
+}
+
+// HeaderTenantResolver 从请求头中获取租户, header 为空时使用 X-Tenant-ID.
+func HeaderTenantResolver(header string) TenantResolver {
+	if header == "" {
+		header = DefaultTenantHeader
+	}
+
+	return TenantResolverFunc(func(c *gin.Context) (string, error) {
+		return resolved(strings.TrimSpace(c.GetHeader(header)))
+	})
+}
+
+// SubdomainTenantResolver 从域名中获取租户, 如 baseDomain 为 example.com 时
+// acme.example.com 的租户为 acme, 只支持一级子域名.
+func SubdomainTenantResolver(baseDomain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+
+	return TenantResolverFunc(func(c *gin.Context) (string, error) {
+		host := strings.ToLower(c.Request.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		sub := strings.TrimSuffix(host, suffix)
+		if sub == host || strings.Contains(sub, ".") {
+			return "", ErrTenantNotResolved
+		}
+
+		return resolved(sub)
+	})
+}
+
+// ChainTenantResolver 依次使用 resolvers, 返回第一个得到的租户.
+func ChainTenantResolver(resolvers ...TenantResolver) TenantResolver {
+	return TenantResolverFunc(func(c *gin.Context) (string, error) {
+		for _, r := range resolvers {
+			tenant, err := r.Resolve(c)
+			if errors.Is(err, ErrTenantNotResolved) {
+				continue
+			}
+
+			return tenant, err
+		}
+
+		return "", ErrTenantNotResolved
+	})
+}
+
+// AllowTenants 只允许 tenants 中的租户, 其他租户返回 ErrTenantNotAllowed.
+func AllowTenants(resolver TenantResolver, tenants ...string) TenantResolver {
+	allowed := make(map[string]struct{}, len(tenants))
+	for _, tenant := range tenants {
+		allowed[tenant] = struct{}{}
+	}
+
+	return AllowTenantsFunc(resolver, func(tenant string) bool {
+		_, ok := allowed[tenant]
+
+		return ok
+	})
+}
+
+// AllowTenantsFunc 只允许 allow 返回 true 的租户, 用于租户列表保存在数据库等动态场景.
+func AllowTenantsFunc(resolver TenantResolver, allow func(tenant string) bool) TenantResolver {
+	return TenantResolverFunc(func(c *gin.Context) (string, error) {
+		tenant, err := resolver.Resolve(c)
+		if err != nil {
+			return "", err
+		}
+
+		if !allow(tenant) {
+			return "", ErrTenantNotAllowed
+		}
+
+		return tenant, nil
+	})
+}
+
+func resolved(tenant string) (string, error) {
+	if tenant == "" {
+		return "", ErrTenantNotResolved
+	}
+
+	return tenant, nil
+}
+
+// validDatabaseName 租户名称作为数据库名称时必须符合 mongo 的命名规则.
+func validDatabaseName(name string) bool {
+	if name == "" || len(name) > maxDatabaseNameLen {
+		return false
+	}
+
+	return !strings.ContainsAny(name, "/\\. \"$*<>:|?\x00")
+}
@@ -0,0 +1,98 @@
+package mongodb
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestContext(host string, header map[string]string, keys map[string]interface{}) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+
+	for k, v := range header {
+		c.Request.Header.Set(k, v)
+	}
+
+	for k, v := range keys {
+		c.Set(k, v)
+	}
+
+	return c
+}
+
+func TestTenantResolvers(t *testing.T) {
+	c := newTestContext("acme.example.com:8080", map[string]string{DefaultTenantHeader: "org-h"}, map[string]interface{}{CtxOrgID: "org-c"})
+
+	cases := []struct {
+		name     string
+		resolver TenantResolver
+		want     string
+		err      error
+	}{
+		{"context", ContextTenantResolver(""), "org-c", nil},
+		{"header", HeaderTenantResolver(""), "org-h", nil},
+		{"subdomain", SubdomainTenantResolver("example.com"), "acme", nil},
+		{"other domain", SubdomainTenantResolver("example.org"), "", ErrTenantNotResolved},
+		{"missing", ContextTenantResolver("tenant"), "", ErrTenantNotResolved},
+		{"chain", ChainTenantResolver(ContextTenantResolver("tenant"), HeaderTenantResolver("")), "org-h", nil},
+		{"allowed", AllowTenants(HeaderTenantResolver(""), "org-h"), "org-h", nil},
+		{"not allowed", AllowTenants(HeaderTenantResolver(""), "org-c"), "", ErrTenantNotAllowed},
+	}
+
+	for _, tc := range cases {
+		got, err := tc.resolver.Resolve(c)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("%s: got %q, %v; want %q, %v", tc.name, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestValidDatabaseName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"org-101":  true,
+		"":         false,
+		"a.b":      false,
+		"../admin": false,
+		"a b":      false,
+		"$cmd":     false,
+	} {
+		if got := validDatabaseName(name); got != want {
+			t.Errorf("%q: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCrud_TenantResolveFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name   string
+		crud   *Crud
+		header string
+		status int
+	}{
+		{"not resolved", NewCrud("", "message", testParam{}), "", http.StatusBadRequest},
+		{"not allowed", NewCrud("", "message", testParam{},
+			CrudOptionTenantResolver(AllowTenants(HeaderTenantResolver(""), "org-1"))), "org-2", http.StatusForbidden},
+		{"invalid", NewCrud("", "message", testParam{},
+			CrudOptionTenantResolver(HeaderTenantResolver(""))), "../admin", http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		r := gin.New()
+		CRUD(r.Group("/api"), "/message", tc.crud)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/message", nil)
+		req.Header.Set(DefaultTenantHeader, tc.header)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d, body: %s", tc.name, w.Code, tc.status, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/qumogu/go-tools/logger"
+	"github.com/qumogu/go-tools/svrctrl"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	FieldIsDelete    = "is_delete"
+	FieldDeletedTime = "deleted_time" // 删除时间, 秒级时间戳
+	FieldDeletedUser = "deleted_user"
+
+	defaultPurgeRetention = 30 * 24 * time.Hour
+	defaultPurgeInterval  = time.Hour
+)
+
+// NewSoftDeleteBase 开启假删除的 Base.
+// 查询(Find, FindWithPage, FindOne, Count, IsExist 等)与更新(UpdateByID, UpdateMany, ReplaceOneById, Upsert 等)
+// 默认排除 is_delete 为 true 的文档, 需要包含已删除文档时使用 FindWithDeleted.
+func NewSoftDeleteBase(collection Collection) *Base {
+	return &Base{collection: collection, softDelete: true}
+}
+
+// SoftDelete 是否开启了假删除.
+func (b *Base) SoftDelete() bool {
+	return b.softDelete
+}
+
+// notDeleted 开启假删除时在过滤条件中排除已删除的文档, 过滤条件中已指定 is_delete 时不修改.
+func (b *Base) notDeleted(filter interface{}) interface{} {
+	if !b.softDelete {
+		return filter
+	}
+
+	if hasField(filter, FieldIsDelete) {
+		return filter
+	}
+
+	return addCondition(filter, FieldIsDelete, bson.M{"$ne": true})
+}
+
+// SoftDeleteByIds 假删除, 记录删除时间与删除人, 返回本次删除的个数.
+func (b *Base) SoftDeleteByIds(ctx context.Context, ids []string, user string) (int64, error) {
+	return b.softDeleteByFilter(ctx, bson.M{"_id": bson.M{"$in": b.getObjecIds(ids)}}, user)
+}
+
+func (b *Base) softDeleteByFilter(ctx context.Context, filter interface{}, user string) (int64, error) {
+	filter = addCondition(filter, FieldIsDelete, bson.M{"$ne": true})
+
+	update := bson.M{
+		"$set": bson.M{
+			FieldIsDelete:    true,
+			FieldDeletedTime: time.Now().Unix(),
+			FieldDeletedUser: user,
+		},
+	}
+
+	ctx, cancel := NewContextWithTimeout(ctx)
+	defer cancel()
+
+	result, err := b.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// Restore 恢复假删除的文档, 返回恢复的个数.
+func (b *Base) Restore(ctx context.Context, ids []string) (int64, error) {
+	return b.restoreByFilter(ctx, bson.M{"_id": bson.M{"$in": b.getObjecIds(ids)}})
+}
+
+func (b *Base) restoreByFilter(ctx context.Context, filter interface{}) (int64, error) {
+	filter = addCondition(filter, FieldIsDelete, true)
+
+	update := bson.M{
+		"$set": bson.M{
+			FieldIsDelete: false,
+		},
+		"$unset": bson.M{
+			FieldDeletedTime: "",
+			FieldDeletedUser: "",
+		},
+	}
+
+	ctx, cancel := NewContextWithTimeout(ctx)
+	defer cancel()
+
+	result, err := b.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// FindWithDeleted 查询过滤, 包含已假删除的文档, 返回所有结果，不分页.
+func (b *Base) FindWithDeleted(ctx context.Context, filter interface{}, opt PageOrderIntfc, results interface{}) error {
+	return b.find(ctx, filter, opt, results)
+}
+
+// PurgeDeleted 真删除 before 之前假删除的文档, 返回删除的个数.
+// 没有删除时间的文档(由 DeleteFalseByIds 的旧实现删除)先将删除时间设置为当前时间, 从现在开始计算保留时间.
+func (b *Base) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := NewContextWithTimeout(ctx)
+	defer cancel()
+
+	undated := bson.M{
+		FieldIsDelete:    true,
+		FieldDeletedTime: bson.M{"$exists": false},
+	}
+
+	if _, err := b.collection.UpdateMany(ctx, undated, bson.M{"$set": bson.M{FieldDeletedTime: time.Now().Unix()}}); err != nil {
+		return 0, err
+	}
+
+	filter := bson.M{
+		FieldIsDelete:    true,
+		FieldDeletedTime: bson.M{"$lte": before.Unix()},
+	}
+
+	result, err := b.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+// PurgeConfig 清理任务配置, 零值使用默认值.
+type PurgeConfig struct {
+	Retention time.Duration // 假删除文档的保留时间, 默认 30 天
+	Interval  time.Duration // 清理间隔, 默认 1h
+}
+
+// PurgeJob 定时真删除超过保留时间的假删除文档.
+//
+//	job := mongodb.NewPurgeJob(mongodb.PurgeConfig{Retention: 7 * 24 * time.Hour}, messages)
+//	go job.RunAndRestartOnError(ctx)
+type PurgeJob struct {
+	conf  PurgeConfig
+	bases []*Base
+}
+
+func NewPurgeJob(conf PurgeConfig, bases ...*Base) *PurgeJob {
+	if conf.Retention <= 0 {
+		conf.Retention = defaultPurgeRetention
+	}
+
+	if conf.Interval <= 0 {
+		conf.Interval = defaultPurgeInterval
+	}
+
+	return &PurgeJob{
+		conf:  conf,
+		bases: bases,
+	}
+}
+
+// RunAndRestartOnError 运行清理任务直到 ctx 结束, 出错后由 svrctrl 自动重启.
+func (j *PurgeJob) RunAndRestartOnError(ctx context.Context) error {
+	return svrctrl.RunAndRestartOnError(ctx, "mongo-purge", func() error {
+		return j.Run(ctx)
+	})
+}
+
+// Run 每隔 Interval 清理一次, 直到 ctx 结束.
+func (j *PurgeJob) Run(ctx context.Context) error {
+	for {
+		if _, err := j.Purge(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.conf.Interval):
+		}
+	}
+}
+
+// Purge 清理一次, 返回删除的总个数.
+func (j *PurgeJob) Purge(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-j.conf.Retention)
+
+	var total int64
+
+	for _, b := range j.bases {
+		n, err := b.PurgeDeleted(ctx, before)
+		if err != nil {
+			return total, err
+		}
+
+		if n > 0 {
+			logger.Infof("purged %d deleted documents from %s", n, b.collection.Name())
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
@@ -0,0 +1,129 @@
+package mongodb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBase_NotDeleted(t *testing.T) {
+	notDeleted := bson.M{"$ne": true}
+
+	b := NewSoftDeleteBase(nil)
+
+	cases := []struct {
+		name   string
+		filter interface{}
+		want   interface{}
+	}{
+		{"nil", nil, bson.M{FieldIsDelete: notDeleted}},
+		{"bson.M", bson.M{"name": "a"}, bson.M{"name": "a", FieldIsDelete: notDeleted}},
+		{"map", map[string]interface{}{"name": "a"}, bson.M{"name": "a", FieldIsDelete: notDeleted}},
+		{"explicit", bson.M{FieldIsDelete: true}, bson.M{FieldIsDelete: true}},
+		{"bson.D", bson.D{{Key: "name", Value: "a"}}, bson.D{{Key: "name", Value: "a"}, {Key: FieldIsDelete, Value: notDeleted}}},
+		{"other", struct{ Name string }{"a"}, bson.M{"$and": bson.A{struct{ Name string }{"a"}, bson.M{FieldIsDelete: notDeleted}}}},
+	}
+
+	for _, c := range cases {
+		if got := b.notDeleted(c.filter); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// 不修改调用方的过滤条件
+	filter := bson.M{"name": "a"}
+	_ = b.notDeleted(filter)
+	if len(filter) != 1 {
+		t.Error("filter of caller should not be modified")
+	}
+
+	if got := NewBase(nil).notDeleted(filter); !reflect.DeepEqual(got, filter) {
+		t.Errorf("filter should be kept without soft delete, got %v", got)
+	}
+}
+
+func TestBase_SoftDeleteUpdateFilter(t *testing.T) {
+	ctx := context.Background()
+	notDeleted := bson.M{"$ne": true}
+	id := primitive.NewObjectID()
+
+	coll := &recordCollection{matched: 1, doc: bson.M{"_id": id}}
+	b := NewSoftDeleteBase(coll)
+
+	cases := []struct {
+		name string
+		fn   func() error
+		want interface{}
+	}{
+		{"UpdateByID", func() error {
+			return b.UpdateByID(ctx, id.Hex(), bson.M{"name": "a"})
+		}, bson.M{"_id": id, FieldIsDelete: notDeleted}},
+		{"UpdateOneByFilter", func() error {
+			return b.UpdateOneByFilter(ctx, bson.M{"name": "a"}, bson.M{"name": "b"})
+		}, bson.M{"name": "a", FieldIsDelete: notDeleted}},
+		{"UpdateMany", func() error {
+			return b.UpdateMany(ctx, bson.M{"name": "a"}, bson.M{"$set": bson.M{"name": "b"}})
+		}, bson.M{"name": "a", FieldIsDelete: notDeleted}},
+		{"ReplaceOneById", func() error {
+			return b.ReplaceOneById(ctx, id.Hex(), bson.M{"name": "b"})
+		}, bson.M{"_id": id, FieldIsDelete: notDeleted}},
+		{"Upsert", func() error {
+			_, err := b.Upsert(ctx, bson.M{"name": "a"}, bson.M{"name": "a"})
+			return err
+		}, bson.M{"name": "a", FieldIsDelete: notDeleted}},
+		{"Restore", func() error {
+			_, err := b.Restore(ctx, []string{id.Hex()})
+			return err
+		}, bson.M{"_id": bson.M{"$in": []primitive.ObjectID{id}}, FieldIsDelete: true}},
+		{"SoftDeleteByIds", func() error {
+			_, err := b.SoftDeleteByIds(ctx, []string{id.Hex()}, "u1")
+			return err
+		}, bson.M{"_id": bson.M{"$in": []primitive.ObjectID{id}}, FieldIsDelete: notDeleted}},
+	}
+
+	for _, c := range cases {
+		if err := c.fn(); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		if !reflect.DeepEqual(coll.filter, c.want) {
+			t.Errorf("%s: filter = %v, want %v", c.name, coll.filter, c.want)
+		}
+	}
+}
+
+func TestBase_PurgeDeletedFilter(t *testing.T) {
+	c := &recordCollection{}
+	before := time.Now().Add(-time.Hour)
+
+	if _, err := NewSoftDeleteBase(c).PurgeDeleted(context.Background(), before); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.calls) != 2 {
+		t.Fatalf("calls = %d, want stamp and purge", len(c.calls))
+	}
+
+	// 没有删除时间的旧数据从现在开始计算保留时间, 不会被立即清理
+	stamp := c.calls[0]
+	if want := (bson.M{FieldIsDelete: true, FieldDeletedTime: bson.M{"$exists": false}}); !reflect.DeepEqual(stamp.filter, want) {
+		t.Errorf("stamp filter = %v, want %v", stamp.filter, want)
+	}
+
+	set, _ := stamp.update.(bson.M)["$set"].(bson.M)
+	if deleted, _ := set[FieldDeletedTime].(int64); deleted <= before.Unix() {
+		t.Errorf("undated documents should be stamped with now, got %v", stamp.update)
+	}
+
+	want := bson.M{
+		FieldIsDelete:    true,
+		FieldDeletedTime: bson.M{"$lte": before.Unix()},
+	}
+	if !reflect.DeepEqual(c.calls[1].filter, want) {
+		t.Errorf("purge filter = %v, want %v", c.calls[1].filter, want)
+	}
+}
@@ -0,0 +1,477 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	FieldOrgID = "org_id"
+
+	// CtxOrgID 以字符串为 key 保存租户ID的 context, 如 example 中的 Gin2UserContext.
+	CtxOrgID = "org_id"
+)
+
+var (
+	ErrNoTenant       = errors.New("tenant not found in context")
+	ErrTenantMismatch = errors.New("org_id does not match the tenant")
+)
+
+type orgIDCtxKey struct{}
+
+type superAdminCtxKey struct{}
+
+// WithOrgID 返回保存了租户ID的 context.
+func WithOrgID(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, orgIDCtxKey{}, orgID)
+}
+
+// OrgIDFromContext 返回 context 中的租户ID, 兼容以 CtxOrgID 字符串为 key 保存的租户ID.
+func OrgIDFromContext(ctx context.Context) string {
+	if orgID, ok := ctx.Value(orgIDCtxKey{}).(string); ok && orgID != "" {
+		return orgID
+	}
+
+	orgID, _ := ctx.Value(CtxOrgID).(string)
+
+	return orgID
+}
+
+// WithSuperAdmin 返回超级管理员的 context, TenantBase 不再按租户限制数据.
+// 只能显式调用, 不会根据用户信息中的 is_superadmin 自动开启.
+func WithSuperAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, superAdminCtxKey{}, true)
+}
+
+func IsSuperAdmin(ctx context.Context) bool {
+	ok, _ := ctx.Value(superAdminCtxKey{}).(bool)
+
+	return ok
+}
+
+var _ BaseInterfc = (*TenantBase)(nil)
+
+// TenantBase 按租户隔离数据的 Base.
+// 租户ID从 context 中获取, 所有查询、更新与删除的过滤条件都加上 org_id,
+// 插入的文档写入 org_id, 修改 org_id 的更新返回 ErrTenantMismatch.
+// context 中没有租户ID时返回 ErrNoTenant, 超级管理员需要使用 WithSuperAdmin 显式跳过.
+//
+//	messages := mongodb.NewTenantBase(mongodb.NewSoftDeleteBase(db.Collection("message")))
+//	err := messages.Find(mongodb.WithOrgID(ctx, orgID), bson.M{"status": 1}, nil, &results)
+type TenantBase struct {
+	base *Base
+}
+
+func NewTenantBase(base *Base) *TenantBase {
+	return &TenantBase{base: base}
+}
+
+// tenant 返回 context 中的租户ID, 超级管理员返回 bypass 为 true.
+func (t *TenantBase) tenant(ctx context.Context) (orgID string, bypass bool, err error) {
+	if IsSuperAdmin(ctx) {
+		return "", true, nil
+	}
+
+	orgID = OrgIDFromContext(ctx)
+	if orgID == "" {
+		return "", false, ErrNoTenant
+	}
+
+	return orgID, false, nil
+}
+
+// scope 在过滤条件中加上租户ID.
+func (t *TenantBase) scope(ctx context.Context, filter interface{}) (interface{}, error) {
+	orgID, bypass, err := t.tenant(ctx)
+	if err != nil || bypass {
+		return filter, err
+	}
+
+	return addCondition(filter, FieldOrgID, orgID), nil
+}
+
+// stamp 在插入的文档中写入租户ID.
+func (t *TenantBase) stamp(ctx context.Context, data interface{}) (interface{}, error) {
+	orgID, bypass, err := t.tenant(ctx)
+	if err != nil || bypass {
+		return data, err
+	}
+
+	return setOrgID(data, orgID, true)
+}
+
+// checkSet 检查 $set 的字段, org_id 为空时设置为租户ID, 不允许修改为其他租户.
+func (t *TenantBase) checkSet(ctx context.Context, data interface{}) (interface{}, error) {
+	orgID, bypass, err := t.tenant(ctx)
+	if err != nil || bypass {
+		return data, err
+	}
+
+	return setOrgID(data, orgID, false)
+}
+
+// checkUpdate 检查包含更新操作符的文档, 只允许 $set/$setOnInsert 将 org_id 设置为当前租户,
+// 其他操作符不能修改 org_id, $rename 也不能将其他字段重命名为 org_id.
+func (t *TenantBase) checkUpdate(ctx context.Context, update interface{}) (interface{}, error) {
+	orgID, bypass, err := t.tenant(ctx)
+	if err != nil || bypass {
+		return update, err
+	}
+
+	doc, err := toBsonD(update)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range doc {
+		if !strings.HasPrefix(e.Key, "$") {
+			return setOrgID(doc, orgID, false)
+		}
+
+		if e.Key == "$set" || e.Key == "$setOnInsert" {
+			if doc[i].Value, err = setOrgID(e.Value, orgID, false); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if hasField(e.Value, FieldOrgID) {
+			return nil, ErrTenantMismatch
+		}
+
+		// $rename 的目标字段为 org_id 时会覆盖租户ID
+		if e.Key == "$rename" {
+			if renamed, err := renamesTo(e.Value, FieldOrgID); err != nil || renamed {
+				return nil, ErrTenantMismatch
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+// renamesTo $rename 中是否有字段被重命名为 field 或其子字段.
+func renamesTo(rename interface{}, field string) (bool, error) {
+	doc, err := toBsonD(rename)
+	if err != nil {
+		return false, err
+	}
+
+	for _, e := range doc {
+		if to, _ := e.Value.(string); to == field || strings.HasPrefix(to, field+".") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// setOrgID 文档中的 org_id 为空时设置为 orgID, 与 orgID 不一致时返回 ErrTenantMismatch.
+// add 为 true 时文档中没有 org_id 则增加.
+func setOrgID(data interface{}, orgID string, add bool) (bson.D, error) {
+	doc, err := toBsonD(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range doc {
+		if e.Key != FieldOrgID {
+			continue
+		}
+
+		if v, _ := e.Value.(string); v != "" && v != orgID {
+			return nil, ErrTenantMismatch
+		}
+
+		doc[i].Value = orgID
+
+		return doc, nil
+	}
+
+	if add {
+		doc = append(doc, bson.E{Key: FieldOrgID, Value: orgID})
+	}
+
+	return doc, nil
+}
+
+// toBsonD 将 model、map 等编码为 bson.D, 字段名使用 bson tag.
+func toBsonD(data interface{}) (bson.D, error) {
+	raw, err := bson.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := bson.D{}
+	if err = bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func (t *TenantBase) idsFilter(ids []string) bson.M {
+	return bson.M{"_id": bson.M{"$in": t.base.getObjecIds(ids)}}
+}
+
+func (t *TenantBase) idFilter(id string) (bson.M, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{"_id": objID}, nil
+}
+
+func (t *TenantBase) Create(ctx context.Context, data interface{}) (string, error) {
+	data, err := t.stamp(ctx, data)
+	if err != nil {
+		return "", err
+	}
+
+	return t.base.Create(ctx, data)
+}
+
+func (t *TenantBase) CreateMany(ctx context.Context, documents []interface{}) ([]string, error) {
+	docs := make([]interface{}, 0, len(documents))
+
+	for _, document := range documents {
+		doc, err := t.stamp(ctx, document)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return t.base.CreateMany(ctx, docs)
+}
+
+func (t *TenantBase) DeleteFalseByIds(ctx context.Context, ids []string) (int64, error) {
+	return t.SoftDeleteByIds(ctx, ids, "")
+}
+
+func (t *TenantBase) SoftDeleteByIds(ctx context.Context, ids []string, user string) (int64, error) {
+	filter, err := t.scope(ctx, t.idsFilter(ids))
+	if err != nil {
+		return 0, err
+	}
+
+	return t.base.softDeleteByFilter(ctx, filter, user)
+}
+
+func (t *TenantBase) Restore(ctx context.Context, ids []string) (int64, error) {
+	filter, err := t.scope(ctx, t.idsFilter(ids))
+	if err != nil {
+		return 0, err
+	}
+
+	return t.base.restoreByFilter(ctx, filter)
+}
+
+func (t *TenantBase) DeleteByIds(ctx context.Context, ids []string) (int64, error) {
+	return t.DeleteByFilter(ctx, t.idsFilter(ids))
+}
+
+// DeleteByIdsOrgId orgId 必须与 context 中的租户一致.
+func (t *TenantBase) DeleteByIdsOrgId(ctx context.Context, ids []string, orgId string) (int64, error) {
+	orgID, bypass, err := t.tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if !bypass && orgId != orgID {
+		return 0, ErrTenantMismatch
+	}
+
+	return t.base.DeleteByIdsOrgId(ctx, ids, orgId)
+}
+
+func (t *TenantBase) DeleteByFilter(ctx context.Context, filter interface{}) (int64, error) {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.base.DeleteByFilter(ctx, filter)
+}
+
+func (t *TenantBase) UpdateByID(ctx context.Context, id string, data interface{}) error {
+	filter, err := t.idFilter(id)
+	if err != nil {
+		return err
+	}
+
+	return t.UpdateOneByFilter(ctx, filter, data)
+}
+
+func (t *TenantBase) UpdateOneByFilter(ctx context.Context, filter interface{}, data interface{}) error {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	data, err = t.checkSet(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	return t.base.UpdateOneByFilter(ctx, filter, data)
+}
+
+func (t *TenantBase) UpdateMany(ctx context.Context, filter interface{}, data interface{}) error {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	data, err = t.checkUpdate(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	return t.base.UpdateMany(ctx, filter, data)
+}
+
+func (t *TenantBase) ReplaceOneById(ctx context.Context, id string, data interface{}) error {
+	filter, err := t.idFilter(id)
+	if err != nil {
+		return err
+	}
+
+	scoped, err := t.scope(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	data, err = t.stamp(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	return t.base.replaceOne(ctx, scoped, data)
+}
+
+func (t *TenantBase) UpdateByIDWithVersion(ctx context.Context, id string, version int64, data interface{}) error {
+	filter, err := t.idFilter(id)
+	if err != nil {
+		return err
+	}
+
+	scoped, err := t.scope(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	data, err = t.checkSet(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	return t.base.updateWithVersion(ctx, scoped, version, data)
+}
+
+func (t *TenantBase) Upsert(ctx context.Context, filter interface{}, data interface{}) (string, error) {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return "", err
+	}
+
+	data, err = t.stamp(ctx, data)
+	if err != nil {
+		return "", err
+	}
+
+	return t.base.Upsert(ctx, filter, data)
+}
+
+func (t *TenantBase) Find(ctx context.Context, filter interface{}, opt PageOrderIntfc, results interface{}) error {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return t.base.Find(ctx, filter, opt, results)
+}
+
+func (t *TenantBase) FindWithDeleted(ctx context.Context, filter interface{}, opt PageOrderIntfc, results interface{}) error {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return t.base.FindWithDeleted(ctx, filter, opt, results)
+}
+
+func (t *TenantBase) FindWithPage(ctx context.Context, filter interface{}, pageOrder PageOrderIntfc, results interface{}) (int64, error) {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.base.FindWithPage(ctx, filter, pageOrder, results)
+}
+
+func (t *TenantBase) FindWithCursor(ctx context.Context, filter interface{}, q CursorQuery, results interface{}) (string, error) {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return "", err
+	}
+
+	return t.base.FindWithCursor(ctx, filter, q, results)
+}
+
+func (t *TenantBase) FindByNameWithPage(ctx context.Context, name string, pageOrder PageOrderIntfc, results interface{}) (int64, error) {
+	filter := bson.M{}
+
+	if name != "" {
+		filter["name"] = name
+	}
+
+	return t.FindWithPage(ctx, filter, pageOrder, results)
+}
+
+func (t *TenantBase) FindOne(ctx context.Context, filter interface{}, result interface{}) error {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return t.base.FindOne(ctx, filter, result)
+}
+
+func (t *TenantBase) FindOneByID(ctx context.Context, id string, result interface{}) error {
+	filter, err := t.idFilter(id)
+	if err != nil {
+		return err
+	}
+
+	return t.FindOne(ctx, filter, result)
+}
+
+func (t *TenantBase) IsExist(ctx context.Context, filter interface{}) (bool, error) {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+
+	return t.base.IsExist(ctx, filter)
+}
+
+func (t *TenantBase) IsExistByName(ctx context.Context, name string) (bool, error) {
+	return t.IsExist(ctx, bson.M{"name": name})
+}
+
+func (t *TenantBase) Count(ctx context.Context, filter interface{}) (int64, error) {
+	filter, err := t.scope(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.base.Count(ctx, filter)
+}
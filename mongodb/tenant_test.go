@@ -0,0 +1,117 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type tenantDoc struct {
+	Name  string `bson:"name"`
+	OrgID string `bson:"org_id"`
+}
+
+func TestOrgIDFromContext(t *testing.T) {
+	if got := OrgIDFromContext(WithOrgID(context.Background(), "org1")); got != "org1" {
+		t.Errorf("got %q, want org1", got)
+	}
+
+	// 兼容 Gin2UserContext 以字符串为 key 保存的租户ID
+	ctx := context.WithValue(context.Background(), CtxOrgID, "org2")
+	if got := OrgIDFromContext(ctx); got != "org2" {
+		t.Errorf("got %q, want org2", got)
+	}
+}
+
+func TestTenantBase_Scope(t *testing.T) {
+	tb := NewTenantBase(NewBase(nil))
+
+	if _, err := tb.scope(context.Background(), bson.M{}); !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("err = %v, want ErrNoTenant", err)
+	}
+
+	ctx := WithOrgID(context.Background(), "org1")
+
+	got, err := tb.scope(ctx, bson.M{"name": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bson.M{"name": "a", FieldOrgID: "org1"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// 过滤条件中指定了其他租户时仍然限制为当前租户
+	got, _ = tb.scope(ctx, bson.M{FieldOrgID: "org2"})
+	want := bson.M{"$and": bson.A{bson.M{FieldOrgID: "org2"}, bson.M{FieldOrgID: "org1"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	filter := bson.M{"name": "a"}
+	if got, _ = tb.scope(WithSuperAdmin(context.Background()), filter); !reflect.DeepEqual(got, filter) {
+		t.Errorf("super admin should not be scoped, got %v", got)
+	}
+}
+
+func TestTenantBase_Stamp(t *testing.T) {
+	tb := NewTenantBase(NewBase(nil))
+	ctx := WithOrgID(context.Background(), "org1")
+
+	got, err := tb.stamp(ctx, &tenantDoc{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bson.D{{Key: "name", Value: "a"}, {Key: FieldOrgID, Value: "org1"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got, _ = tb.stamp(ctx, bson.M{"name": "a"})
+	if want := (bson.D{{Key: "name", Value: "a"}, {Key: FieldOrgID, Value: "org1"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err = tb.stamp(ctx, tenantDoc{Name: "a", OrgID: "org2"}); !errors.Is(err, ErrTenantMismatch) {
+		t.Errorf("err = %v, want ErrTenantMismatch", err)
+	}
+}
+
+func TestTenantBase_CheckUpdate(t *testing.T) {
+	tb := NewTenantBase(NewBase(nil))
+	ctx := WithOrgID(context.Background(), "org1")
+
+	if _, err := tb.checkSet(ctx, bson.M{FieldOrgID: "org2"}); !errors.Is(err, ErrTenantMismatch) {
+		t.Errorf("err = %v, want ErrTenantMismatch", err)
+	}
+
+	// model 中 org_id 为空时不能清空租户ID
+	got, err := tb.checkSet(ctx, tenantDoc{Name: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bson.D{{Key: "name", Value: "b"}, {Key: FieldOrgID, Value: "org1"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err = tb.checkUpdate(ctx, bson.M{"$set": bson.M{"name": "b"}}); err != nil {
+		t.Errorf("err = %v", err)
+	}
+
+	if _, err = tb.checkUpdate(ctx, bson.M{"$rename": bson.M{"name": "title"}}); err != nil {
+		t.Errorf("rename of other fields should be allowed, err = %v", err)
+	}
+
+	for _, update := range []bson.M{
+		{"$set": bson.M{FieldOrgID: "org2"}},
+		{"$unset": bson.M{FieldOrgID: ""}},
+		{"$rename": bson.M{FieldOrgID: "tenant"}},
+		{"$rename": bson.M{"tenant": FieldOrgID}},
+		{"$rename": bson.M{"tenant": FieldOrgID + ".id"}},
+	} {
+		if _, err = tb.checkUpdate(ctx, update); !errors.Is(err, ErrTenantMismatch) {
+			t.Errorf("update %v: err = %v, want ErrTenantMismatch", update, err)
+		}
+	}
+}
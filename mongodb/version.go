@@ -0,0 +1,312 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const FieldVersion = "version"
+
+var ErrConflict = errors.New("version conflict")
+
+// ConflictError 文档已被其他请求修改, errors.Is(err, ErrConflict) 为 true.
+type ConflictError struct {
+	Expected int64 // 请求的版本号
+	Current  int64 // 数据库中的版本号
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("version conflict: expected %d, current %d", e.Expected, e.Current)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// WithVersion 返回开启版本号的 Base, 用于乐观锁.
+// 插入的文档版本号为1, 每次更新版本号加1; ReplaceOneById 与 Upsert 以文档中的版本号作为期望的版本号,
+// 与数据库不一致时返回 ConflictError. 需要按版本号更新时使用 UpdateByIDWithVersion.
+//
+//	configs := mongodb.NewBase(db.Collection("configuration")).WithVersion()
+//	err := configs.UpdateByIDWithVersion(ctx, id, 3, bson.M{"name": "b"})
+func (b *Base) WithVersion() *Base {
+	versioned := *b
+	versioned.versioned = true
+
+	return &versioned
+}
+
+// Versioned 是否开启了版本号.
+func (b *Base) Versioned() bool {
+	return b.versioned
+}
+
+// UpdateByIDWithVersion 只有版本号为 version 时才更新, 并将版本号加1.
+// 版本号不一致时返回 ConflictError, 文档不存在时返回 ErrNotFound.
+// version 为0时匹配没有版本号的文档.
+func (b *Base) UpdateByIDWithVersion(ctx context.Context, id string, version int64, data interface{}) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	return b.updateWithVersion(ctx, bson.M{"_id": objID}, version, data)
+}
+
+func (b *Base) updateWithVersion(ctx context.Context, filter interface{}, version int64, data interface{}) error {
+	doc, err := withoutField(data, FieldVersion)
+	if err != nil {
+		return err
+	}
+
+	update := bson.D{{Key: "$inc", Value: bson.M{FieldVersion: 1}}}
+	if len(doc) > 0 {
+		update = append(bson.D{{Key: "$set", Value: doc}}, update...)
+	}
+
+	ctx, cancel := NewContextWithTimeout(ctx)
+	defer cancel()
+
+	cond := addCondition(b.notDeleted(filter), FieldVersion, versionCondition(version))
+
+	result, err := b.collection.UpdateOne(ctx, cond, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return b.conflict(ctx, filter, version)
+	}
+
+	return nil
+}
+
+// replaceOne 替换文档, 开启版本号时以文档中的版本号作为期望的版本号.
+func (b *Base) replaceOne(ctx context.Context, filter interface{}, data interface{}) error {
+	ctx, cancel := NewContextWithTimeout(ctx)
+	defer cancel()
+
+	if !b.versioned {
+		_, err := b.collection.ReplaceOne(ctx, b.notDeleted(filter), data)
+
+		return err
+	}
+
+	cond, doc, version, err := b.expectVersion(filter, data)
+	if err != nil {
+		return err
+	}
+
+	result, err := b.collection.ReplaceOne(ctx, cond, doc)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return b.conflict(ctx, filter, version)
+	}
+
+	return nil
+}
+
+// expectVersion 以文档中的版本号作为期望的版本号, 返回带版本号条件的过滤条件与版本号加1的文档.
+func (b *Base) expectVersion(filter interface{}, data interface{}) (interface{}, bson.D, int64, error) {
+	doc, err := toBsonD(data)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	version, _ := versionOf(doc)
+	doc = setField(doc, FieldVersion, version+1)
+
+	return addCondition(b.notDeleted(filter), FieldVersion, versionCondition(version)), doc, version, nil
+}
+
+// conflict 按版本号更新失败时, 区分文档不存在与版本号不一致.
+func (b *Base) conflict(ctx context.Context, filter interface{}, expected int64) error {
+	opt := options.FindOne().SetProjection(bson.M{FieldVersion: 1})
+
+	raw, err := b.collection.FindOne(ctx, b.notDeleted(filter), opt).DecodeBytes()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	current, _ := versionOf(raw)
+
+	return &ConflictError{Expected: expected, Current: current}
+}
+
+// setUpdate 返回 $set 更新, 开启版本号时版本号加1.
+func (b *Base) setUpdate(data interface{}) (interface{}, error) {
+	if !b.versioned {
+		return bson.M{"$set": data}, nil
+	}
+
+	doc, err := withoutField(data, FieldVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	update := bson.D{{Key: "$inc", Value: bson.M{FieldVersion: 1}}}
+	if len(doc) > 0 {
+		update = append(bson.D{{Key: "$set", Value: doc}}, update...)
+	}
+
+	return update, nil
+}
+
+// incVersion 在包含更新操作符的文档中将版本号加1, 其他操作符不能修改版本号.
+func (b *Base) incVersion(update interface{}) (interface{}, error) {
+	if !b.versioned {
+		return update, nil
+	}
+
+	doc, err := toBsonD(update)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(bson.D, 0, len(doc)+1)
+
+	for _, e := range doc {
+		if e.Key == "$inc" {
+			continue
+		}
+
+		if strings.HasPrefix(e.Key, "$") {
+			if e.Value, err = withoutField(e.Value, FieldVersion); err != nil {
+				return nil, err
+			}
+		}
+
+		result = append(result, e)
+	}
+
+	inc := bson.D{}
+	if v, ok := findField(doc, "$inc"); ok {
+		if inc, err = withoutField(v, FieldVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(result, bson.E{Key: "$inc", Value: append(inc, bson.E{Key: FieldVersion, Value: 1})}), nil
+}
+
+// stampVersion 插入的文档版本号为1.
+func (b *Base) stampVersion(data interface{}) (interface{}, error) {
+	if !b.versioned {
+		return data, nil
+	}
+
+	doc, err := toBsonD(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return setField(doc, FieldVersion, int64(1)), nil
+}
+
+// versionCondition 版本号为 version 的条件, 0 同时匹配没有版本号的文档.
+func versionCondition(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{nil, 0}}
+	}
+
+	return version
+}
+
+// versionOf 返回文档中的版本号, doc 可以为 model、map、bson.D 或 bson.Raw.
+func versionOf(doc interface{}) (int64, bool) {
+	var raw bson.Raw
+
+	switch d := doc.(type) {
+	case bson.Raw:
+		raw = d
+	default:
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			return 0, false
+		}
+
+		raw = data
+	}
+
+	v, err := raw.LookupErr(FieldVersion)
+	if err != nil {
+		return 0, false
+	}
+
+	if n, ok := v.AsInt64OK(); ok {
+		return n, true
+	}
+
+	if f, ok := v.DoubleOK(); ok {
+		return int64(f), true
+	}
+
+	return 0, false
+}
+
+// ParseVersion 解析 If-Match 请求头中的版本号, 如 "3" 或 W/"3".
+func ParseVersion(etag string) (int64, error) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+
+	return strconv.ParseInt(strings.Trim(etag, `"`), 10, 64)
+}
+
+// FormatVersion 返回版本号对应的 ETag.
+func FormatVersion(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+func findField(doc bson.D, key string) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+func setField(doc bson.D, key string, value interface{}) bson.D {
+	for i, e := range doc {
+		if e.Key == key {
+			doc[i].Value = value
+
+			return doc
+		}
+	}
+
+	return append(doc, bson.E{Key: key, Value: value})
+}
+
+// withoutField 编码为 bson.D 并去掉 key.
+func withoutField(data interface{}, key string) (bson.D, error) {
+	doc, err := toBsonD(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(bson.D, 0, len(doc))
+
+	for _, e := range doc {
+		if e.Key != key {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
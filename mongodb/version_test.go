@@ -0,0 +1,188 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestConflictError(t *testing.T) {
+	var err error = &ConflictError{Expected: 2, Current: 3}
+	if !errors.Is(err, ErrConflict) {
+		t.Error("ConflictError should be ErrConflict")
+	}
+
+	conflict := &ConflictError{}
+	if !errors.As(err, &conflict) || conflict.Current != 3 {
+		t.Errorf("unexpected conflict: %+v", conflict)
+	}
+}
+
+func TestBase_VersionUpdate(t *testing.T) {
+	b := NewBase(nil)
+	if got, _ := b.setUpdate(bson.M{"name": "a"}); !reflect.DeepEqual(got, bson.M{"$set": bson.M{"name": "a"}}) {
+		t.Errorf("update should not change without version, got %v", got)
+	}
+
+	b = b.WithVersion()
+
+	got, err := b.setUpdate(bson.M{"name": "a", FieldVersion: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "name", Value: "a"}}},
+		{Key: "$inc", Value: bson.M{FieldVersion: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got, err = b.incVersion(bson.D{
+		{Key: "$set", Value: bson.M{FieldVersion: 7}},
+		{Key: "$inc", Value: bson.M{"count": 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want = bson.D{
+		{Key: "$set", Value: bson.D{}},
+		{Key: "$inc", Value: bson.D{{Key: "count", Value: int32(1)}, {Key: FieldVersion, Value: 1}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	doc, _ := b.stampVersion(bson.M{"name": "a"})
+	if v, ok := versionOf(doc); !ok || v != 1 {
+		t.Errorf("version of inserted document = %d, want 1", v)
+	}
+}
+
+func TestBase_VersionUpsert(t *testing.T) {
+	ctx := context.Background()
+	c := &recordCollection{}
+	b := NewBase(c).WithVersion()
+
+	// 新增的文档版本号为1
+	if _, err := b.Upsert(ctx, bson.M{"name": "a"}, bson.M{"name": "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := bson.M{"name": "a", FieldVersion: bson.M{"$in": bson.A{nil, 0}}}
+	if !reflect.DeepEqual(c.filter, want) {
+		t.Errorf("filter = %v, want %v", c.filter, want)
+	}
+	if v, _ := versionOf(c.update); v != 1 {
+		t.Errorf("version of upserted document = %d, want 1", v)
+	}
+
+	// 以文档中的版本号作为期望的版本号
+	if _, err := b.Upsert(ctx, bson.M{"name": "a"}, bson.M{"name": "a", FieldVersion: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want = (bson.M{"name": "a", FieldVersion: int64(3)}); !reflect.DeepEqual(c.filter, want) {
+		t.Errorf("filter = %v, want %v", c.filter, want)
+	}
+	if v, _ := versionOf(c.update); v != 4 {
+		t.Errorf("version of replaced document = %d, want 4", v)
+	}
+
+	// 更新已有文档时返回已有文档的主键
+	id := primitive.NewObjectID()
+	c.matched = 1
+
+	if got, err := b.Upsert(ctx, bson.M{"_id": id}, bson.M{"name": "a", FieldVersion: 4}); err != nil || got != id.Hex() {
+		t.Errorf("id = %s, err = %v, want id from filter", got, err)
+	}
+
+	c.doc = bson.M{"_id": id}
+	if got, err := b.Upsert(ctx, bson.M{"name": "a"}, bson.M{"name": "a", FieldVersion: 5}); err != nil || got != id.Hex() {
+		t.Errorf("id = %s, err = %v, want id of matched document", got, err)
+	}
+
+	// 版本号不一致时插入违反唯一索引
+	c.matched = 0
+	c.err = mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}
+	c.doc = bson.M{FieldVersion: int64(5)}
+
+	_, err := b.Upsert(ctx, bson.M{"name": "a"}, bson.M{"name": "a", FieldVersion: 3})
+
+	conflict := &ConflictError{}
+	if !errors.As(err, &conflict) || conflict.Expected != 3 || conflict.Current != 5 {
+		t.Errorf("err = %v, want conflict 3 != 5", err)
+	}
+}
+
+func TestVersionOf(t *testing.T) {
+	type doc struct {
+		Version int `bson:"version"`
+	}
+
+	raw, _ := bson.Marshal(bson.M{FieldVersion: int64(5)})
+
+	for _, c := range []struct {
+		doc  interface{}
+		want int64
+		ok   bool
+	}{
+		{doc{Version: 3}, 3, true},
+		{map[string]interface{}{FieldVersion: float64(4)}, 4, true}, // json 请求体
+		{bson.Raw(raw), 5, true},
+		{bson.M{"name": "a"}, 0, false},
+	} {
+		if got, ok := versionOf(c.doc); got != c.want || ok != c.ok {
+			t.Errorf("%v: got %d, %v; want %d, %v", c.doc, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for etag, want := range map[string]int64{`"3"`: 3, `W/"4"`: 4, "5": 5} {
+		if got, err := ParseVersion(etag); err != nil || got != want {
+			t.Errorf("%s: got %d, %v", etag, got, err)
+		}
+	}
+
+	if _, err := ParseVersion(`"abc"`); err == nil {
+		t.Error("expect error for invalid etag")
+	}
+
+	if got := FormatVersion(3); got != `"3"` {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestCrud_UpdateVersionRequired(t *testing.T) {
+	// GetDB 不访问数据库, 不需要 mongo 服务
+	DefaultMongoMgr = NewMongoManager(MongoConf{URI: "mongodb://127.0.0.1:27017"})
+	defer func() {
+		_ = DefaultMongoMgr.Close(context.Background())
+		DefaultMongoMgr = nil
+	}()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	CRUD(r.Group("/api"), "/message", NewCrud("test", "message", testParam{}, CrudOptionVersion()))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/message/upd/63c7b7e4a1b2c3d4e5f60718", strings.NewReader(`{"name":"a"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPreconditionRequired {
+		t.Errorf("status = %d, want 428, body: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,215 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/qumogu/go-tools/trace"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultCollection = "outbox"
+
+	StatusPending = "pending" // 待发送
+	StatusSending = "sending" // 已被 relay 领取, 发送中
+	StatusSent    = "sent"    // 已发送并被 broker 确认
+)
+
+var (
+	ErrNoTransaction = errors.New("outbox: context is not in a mongo transaction")
+	ErrLeaseLost     = errors.New("outbox: lease expired and message was reclaimed")
+)
+
+// Message 发件箱中的一条事件.
+type Message struct {
+	ID          primitive.ObjectID     `bson:"_id" json:"id"`
+	Exchange    string                 `bson:"exchange" json:"exchange"`
+	Key         string                 `bson:"key" json:"key"`
+	ContentType string                 `bson:"content_type" json:"content_type"`
+	Body        []byte                 `bson:"body" json:"body"`
+	Headers     map[string]interface{} `bson:"headers,omitempty" json:"headers,omitempty"`
+
+	Status      string    `bson:"status" json:"status"`
+	Attempts    int       `bson:"attempts" json:"attempts"`             // 已尝试发送次数
+	LastError   string    `bson:"last_error" json:"last_error"`         // 最近一次发送失败原因
+	NextRetry   time.Time `bson:"next_retry" json:"next_retry"`         // 下一次可发送时间
+	LeaseUntil  time.Time `bson:"lease_until" json:"lease_until"`       // 领取租约到期时间, 到期后其他 relay 可重新领取
+	CreatedTime time.Time `bson:"created_time" json:"created_time"`     // 创建时间
+	SentTime    time.Time `bson:"sent_time,omitempty" json:"sent_time"` // 发送成功时间
+}
+
+// Outbox 事务性发件箱.
+// 业务写入与事件写入在同一个 mongo 事务中提交, 由 Relay 异步发布到 rabbitmq,
+// 避免业务写入成功而消息发布失败导致下游丢失事件.
+// mongo 事务要求部署为副本集或分片集群.
+type Outbox struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+// New 使用默认集合 outbox.
+func New(db *mongo.Database) *Outbox {
+	return NewWithCollection(db, DefaultCollection)
+}
+
+func NewWithCollection(db *mongo.Database, collection string) *Outbox {
+	return &Outbox{
+		db:         db,
+		collection: db.Collection(collection),
+	}
+}
+
+// EnsureIndexes 创建 relay 查询待发送事件使用的索引.
+func (o *Outbox) EnsureIndexes(ctx context.Context) error {
+	_, err := o.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_retry", Value: 1}},
+	})
+
+	return err
+}
+
+// Transaction 开启事务执行 fn, fn 中使用 sc 进行的业务写入与 Add 写入的事件一起提交或回滚.
+//
+//	err := ob.Transaction(ctx, func(sc mongo.SessionContext) error {
+//		if _, err := base.Create(sc, order); err != nil {
+//			return err
+//		}
+//		return ob.Add(sc, "order", "order.created", order)
+//	})
+func (o *Outbox) Transaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	return o.db.Client().UseSession(ctx, func(sc mongo.SessionContext) error {
+		_, err := sc.WithTransaction(sc, func(sc mongo.SessionContext) (interface{}, error) {
+			return nil, fn(sc)
+		})
+
+		return err
+	})
+}
+
+// Add 在当前事务中写入一条待发布的事件, msg 以 json 编码.
+// ctx 必须是 Transaction 传入的 SessionContext.
+func (o *Outbox) Add(ctx context.Context, exchange, key string, msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return o.AddMessage(ctx, &Message{
+		Exchange:    exchange,
+		Key:         key,
+		ContentType: "text/json",
+		Body:        body,
+	})
+}
+
+// AddMessage 在当前事务中写入一条待发布的事件, ctx 中的请求ID等关联信息写入消息头.
+func (o *Outbox) AddMessage(ctx context.Context, msg *Message) error {
+	if mongo.SessionFromContext(ctx) == nil {
+		return ErrNoTransaction
+	}
+
+	now := time.Now()
+	if msg.ID.IsZero() {
+		msg.ID = primitive.NewObjectID()
+	}
+	for k, v := range trace.FromContext(ctx) {
+		if msg.Headers == nil {
+			msg.Headers = map[string]interface{}{}
+		}
+		if _, ok := msg.Headers[k]; !ok {
+			msg.Headers[k] = v
+		}
+	}
+	msg.Status = StatusPending
+	msg.NextRetry = now
+	msg.CreatedTime = now
+
+	_, err := o.collection.InsertOne(ctx, msg)
+
+	return err
+}
+
+// claim 领取一条可发送的事件: 待发送且到达重试时间, 或发送中但租约已过期.
+func (o *Outbox) claim(ctx context.Context, lease time.Duration) (*Message, error) {
+	now := time.Now()
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"status": StatusPending, "next_retry": bson.M{"$lte": now}},
+			bson.M{"status": StatusSending, "lease_until": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      StatusSending,
+			"lease_until": now.Add(lease),
+		},
+	}
+	opt := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_retry", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	msg := &Message{}
+
+	err := o.collection.FindOneAndUpdate(ctx, filter, update, opt).Decode(msg)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// leaseFilter 只匹配仍由本次领取持有的事件, 租约到期被其他 relay 重新领取后不再匹配.
+func leaseFilter(msg *Message) bson.M {
+	return bson.M{"_id": msg.ID, "status": StatusSending, "lease_until": msg.LeaseUntil}
+}
+
+// markSent 标记为已发送, 租约已被其他 relay 重新领取时返回 ErrLeaseLost.
+func (o *Outbox) markSent(ctx context.Context, msg *Message) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":     StatusSent,
+			"sent_time":  time.Now(),
+			"last_error": "",
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	return o.updateLeased(ctx, msg, update)
+}
+
+// markFailed 标记为待重试, 租约已被其他 relay 重新领取时返回 ErrLeaseLost.
+func (o *Outbox) markFailed(ctx context.Context, msg *Message, nextRetry time.Time, cause error) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":     StatusPending,
+			"next_retry": nextRetry,
+			"last_error": cause.Error(),
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	return o.updateLeased(ctx, msg, update)
+}
+
+func (o *Outbox) updateLeased(ctx context.Context, msg *Message, update interface{}) error {
+	result, err := o.collection.UpdateOne(ctx, leaseFilter(msg), update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/qumogu/go-tools/logger"
+	"github.com/qumogu/go-tools/mongodb"
+	"github.com/qumogu/go-tools/svrctrl"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	defaultBatchSize      = 100
+	defaultPollInterval   = time.Second
+	defaultLease          = 30 * time.Second
+	defaultMinBackoff     = time.Second
+	defaultMaxBackoff     = 5 * time.Minute
+	defaultPublishTimeout = 10 * time.Second
+)
+
+// Publisher 以确认模式发布 mandatory 消息, 无法路由时返回错误, rabbitmq.RabbitMQ 与 rabbitmq.MemoryBroker 均已实现.
+type Publisher interface {
+	PublishMandatory(ctx context.Context, exchange, key string, pub amqp.Publishing) error
+}
+
+// store 发件箱的领取与状态更新, 由 Outbox 实现.
+type store interface {
+	claim(ctx context.Context, lease time.Duration) (*Message, error)
+	markSent(ctx context.Context, msg *Message) error
+	markFailed(ctx context.Context, msg *Message, nextRetry time.Time, cause error) error
+}
+
+// RelayConfig relay 配置, 零值使用默认值.
+type RelayConfig struct {
+	BatchSize      int           // 每轮最多发送条数, 默认 100
+	PollInterval   time.Duration // 没有待发送事件时的轮询间隔, 默认 1s
+	Lease          time.Duration // 领取事件的租约, relay 崩溃后租约到期由其他 relay 重新发送, 默认 30s
+	MinBackoff     time.Duration // 首次失败后的重试间隔, 之后每次翻倍, 默认 1s
+	MaxBackoff     time.Duration // 最大重试间隔, 默认 5m
+	PublishTimeout time.Duration // 等待 broker 确认的超时时间, 默认 10s
+}
+
+// Relay 将发件箱中待发送的事件发布到 rabbitmq, 发送成功后标记为已发送, 失败则按退避时间重试.
+// 事件至少发送一次, 消费方需要按 MessageId(事件ID) 做幂等.
+type Relay struct {
+	store store
+	pub   Publisher
+	conf  RelayConfig
+}
+
+func NewRelay(outbox *Outbox, pub Publisher, conf RelayConfig) *Relay {
+	return newRelay(outbox, pub, conf)
+}
+
+func newRelay(s store, pub Publisher, conf RelayConfig) *Relay {
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = defaultBatchSize
+	}
+
+	if conf.PollInterval <= 0 {
+		conf.PollInterval = defaultPollInterval
+	}
+
+	if conf.Lease <= 0 {
+		conf.Lease = defaultLease
+	}
+
+	if conf.MinBackoff <= 0 {
+		conf.MinBackoff = defaultMinBackoff
+	}
+
+	if conf.MaxBackoff < conf.MinBackoff {
+		conf.MaxBackoff = defaultMaxBackoff
+	}
+
+	if conf.PublishTimeout <= 0 {
+		conf.PublishTimeout = defaultPublishTimeout
+	}
+
+	return &Relay{
+		store: s,
+		pub:   pub,
+		conf:  conf,
+	}
+}
+
+// RunAndRestartOnError 运行 relay 直到 ctx 结束, 出错后由 svrctrl 自动重启.
+func (r *Relay) RunAndRestartOnError(ctx context.Context) error {
+	return svrctrl.RunAndRestartOnError(ctx, "outbox-relay", func() error {
+		return r.Run(ctx)
+	})
+}
+
+// Run 循环发送待发送的事件直到 ctx 结束.
+// 读写 mongo 失败时返回错误; 发布失败只记录到事件中, 不会返回.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.relay(ctx)
+		if err != nil {
+			return err
+		}
+
+		if n == r.conf.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.conf.PollInterval):
+		}
+	}
+}
+
+// relay 发送一批事件, 返回处理的条数.
+func (r *Relay) relay(ctx context.Context) (int, error) {
+	for i := 0; i < r.conf.BatchSize; i++ {
+		if ctx.Err() != nil {
+			return i, nil
+		}
+
+		msg, err := r.claim(ctx)
+		if err != nil {
+			return i, err
+		}
+
+		if msg == nil {
+			return i, nil
+		}
+
+		if err = r.publish(ctx, msg); err != nil {
+			return i, err
+		}
+	}
+
+	return r.conf.BatchSize, nil
+}
+
+func (r *Relay) claim(ctx context.Context) (*Message, error) {
+	ctx, cancel := mongodb.NewContextWithTimeout(ctx)
+	defer cancel()
+
+	return r.store.claim(ctx, r.conf.Lease)
+}
+
+func (r *Relay) publish(ctx context.Context, msg *Message) error {
+	pub := amqp.Publishing{
+		Headers:      amqp.Table(msg.Headers),
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    msg.ID.Hex(),
+		Timestamp:    msg.CreatedTime,
+		Body:         msg.Body,
+	}
+
+	pubCtx, cancel := context.WithTimeout(ctx, r.conf.PublishTimeout)
+	pubErr := r.pub.PublishMandatory(pubCtx, msg.Exchange, msg.Key, pub)
+	cancel()
+
+	ctx, cancel = mongodb.NewContextWithTimeout(ctx)
+	defer cancel()
+
+	var err error
+	if pubErr != nil {
+		backoff := r.backoff(msg.Attempts + 1)
+		logger.Warnw("outbox relay publish failed", "id", msg.ID.Hex(), "exchange", msg.Exchange,
+			"key", msg.Key, "attempts", msg.Attempts+1, "retry_in", backoff, "error", pubErr)
+
+		err = r.store.markFailed(ctx, msg, time.Now().Add(backoff), pubErr)
+	} else {
+		err = r.store.markSent(ctx, msg)
+	}
+
+	// 租约已过期, 事件由重新领取的 relay 负责, 不覆盖它的状态
+	if errors.Is(err, ErrLeaseLost) {
+		logger.Warnw("outbox relay lease lost", "id", msg.ID.Hex(), "lease_until", msg.LeaseUntil)
+
+		return nil
+	}
+
+	return err
+}
+
+// backoff 第 attempts 次失败后的重试间隔: MinBackoff * 2^(attempts-1), 不超过 MaxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.conf.MinBackoff
+	for i := 1; i < attempts && d < r.conf.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > r.conf.MaxBackoff {
+		d = r.conf.MaxBackoff
+	}
+
+	return d
+}
@@ -0,0 +1,206 @@
+package outbox
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumogu/go-tools/rabbitmq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memStore 内存中的发件箱, 领取与租约规则同 Outbox.
+type memStore struct {
+	mu   sync.Mutex
+	msgs map[primitive.ObjectID]*Message
+}
+
+func newMemStore(msgs ...*Message) *memStore {
+	s := &memStore{msgs: make(map[primitive.ObjectID]*Message)}
+	for _, msg := range msgs {
+		msg.ID = primitive.NewObjectID()
+		msg.Status = StatusPending
+		msg.NextRetry = time.Now()
+		s.msgs[msg.ID] = msg
+	}
+
+	return s
+}
+
+func (s *memStore) claim(_ context.Context, lease time.Duration) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	ready := make([]*Message, 0, len(s.msgs))
+
+	for _, msg := range s.msgs {
+		if (msg.Status == StatusPending && !msg.NextRetry.After(now)) ||
+			(msg.Status == StatusSending && !msg.LeaseUntil.After(now)) {
+			ready = append(ready, msg)
+		}
+	}
+
+	if len(ready) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(ready, func(i, j int) bool { return ready[i].NextRetry.Before(ready[j].NextRetry) })
+
+	msg := ready[0]
+	msg.Status = StatusSending
+	msg.LeaseUntil = now.Add(lease)
+	claimed := *msg
+
+	return &claimed, nil
+}
+
+func (s *memStore) markSent(_ context.Context, msg *Message) error {
+	return s.update(msg, func(m *Message) {
+		m.Status = StatusSent
+		m.SentTime = time.Now()
+		m.LastError = ""
+	})
+}
+
+func (s *memStore) markFailed(_ context.Context, msg *Message, nextRetry time.Time, cause error) error {
+	return s.update(msg, func(m *Message) {
+		m.Status = StatusPending
+		m.NextRetry = nextRetry
+		m.LastError = cause.Error()
+	})
+}
+
+func (s *memStore) update(msg *Message, fn func(m *Message)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.msgs[msg.ID]
+	if !ok || m.Status != StatusSending || !m.LeaseUntil.Equal(msg.LeaseUntil) {
+		return ErrLeaseLost
+	}
+
+	fn(m)
+	m.Attempts++
+
+	return nil
+}
+
+func (s *memStore) get(id primitive.ObjectID) Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return *s.msgs[id]
+}
+
+func TestRelay_Sent(t *testing.T) {
+	mq := rabbitmq.NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareDirectEx("order")
+	_ = mq.DeclareBindQueue("order.created", "order", "created")
+
+	msg := &Message{Exchange: "order", Key: "created", ContentType: "text/json", Body: []byte(`"o1"`)}
+	s := newMemStore(msg)
+	r := newRelay(s, mq, RelayConfig{})
+
+	n, err := r.relay(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("relay n = %d, err = %v", n, err)
+	}
+
+	got := s.get(msg.ID)
+	if got.Status != StatusSent || got.Attempts != 1 {
+		t.Errorf("message should be sent, got %+v", got)
+	}
+
+	peek, _ := mq.PeekMessages("order.created", 1)
+	if len(peek) != 1 || peek[0].MessageId != msg.ID.Hex() {
+		t.Errorf("event should be published with MessageId, got %+v", peek)
+	}
+
+	if n, _ = r.relay(context.Background()); n != 0 {
+		t.Errorf("sent message should not be claimed again, n = %d", n)
+	}
+}
+
+func TestRelay_Unroutable(t *testing.T) {
+	mq := rabbitmq.NewMemoryBroker()
+	defer mq.Close()
+
+	// 邮局没有绑定队列, 消息无法路由
+	_ = mq.DeclareDirectEx("order")
+
+	msg := &Message{Exchange: "order", Key: "created", Body: []byte(`"o1"`)}
+	s := newMemStore(msg)
+	r := newRelay(s, mq, RelayConfig{MinBackoff: time.Minute, MaxBackoff: time.Hour})
+
+	before := time.Now()
+	if _, err := r.relay(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := s.get(msg.ID)
+	if got.Status != StatusPending || got.Attempts != 1 {
+		t.Fatalf("unroutable message should be retried, got %+v", got)
+	}
+
+	if got.NextRetry.Before(before.Add(time.Minute)) {
+		t.Errorf("next retry should wait MinBackoff, got %s", got.NextRetry.Sub(before))
+	}
+
+	if !strings.Contains(got.LastError, rabbitmq.ErrPublishUnroutable.Error()) {
+		t.Errorf("last error should be recorded, got %q", got.LastError)
+	}
+
+	// 未到重试时间不会被领取
+	if n, _ := r.relay(context.Background()); n != 0 {
+		t.Errorf("message should wait for retry, n = %d", n)
+	}
+}
+
+func TestRelay_LeaseLost(t *testing.T) {
+	mq := rabbitmq.NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareBindQueue("order.created", "", "")
+
+	msg := &Message{Key: "order.created", Body: []byte(`"o1"`)}
+	s := newMemStore(msg)
+	r := newRelay(s, mq, RelayConfig{})
+
+	claimed, _ := s.claim(context.Background(), -time.Second)
+
+	// 租约过期后被其他 relay 重新领取并发送成功
+	reclaimed, _ := s.claim(context.Background(), time.Minute)
+	if err := s.markSent(context.Background(), reclaimed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.publish(context.Background(), claimed); err != nil {
+		t.Fatal("lease lost should not be returned as error:", err)
+	}
+
+	if got := s.get(msg.ID); got.Status != StatusSent || got.Attempts != 1 {
+		t.Errorf("late relay should not overwrite the new owner, got %+v", got)
+	}
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	r := newRelay(nil, nil, RelayConfig{MinBackoff: time.Second, MaxBackoff: 5 * time.Second})
+
+	for attempts, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		if got := r.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
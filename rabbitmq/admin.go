@@ -0,0 +1,218 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	_ QueueAdmin = (*RabbitMQ)(nil)
+	_ QueueAdmin = (*MemoryBroker)(nil)
+)
+
+const adminPublishTimeout = 10 * time.Second
+
+var ErrMoveSameQueue = errors.New("move source and target queue are the same")
+
+// QueueAdmin 队列查看与管理, 不依赖 management 插件.
+type QueueAdmin interface {
+	InspectQueue(queue string) (QueueInfo, error)
+	PurgeQueue(queue string) (int, error)
+	DeleteQueue(queue string, ifUnused, ifEmpty bool) (int, error)
+	MoveMessages(from, to string, limit int) (int, error)
+	PeekMessages(queue string, count int) ([]PeekMessage, error)
+}
+
+// QueueInfo 队列状态.
+type QueueInfo struct {
+	Name      string `json:"name"`
+	Messages  int    `json:"messages"`  // 待投递的消息数, 不包含已投递未确认的消息
+	Consumers int    `json:"consumers"` // 消费者数量
+}
+
+// PeekMessage 查看但不消费的消息.
+type PeekMessage struct {
+	Exchange        string     `json:"exchange"`
+	RoutingKey      string     `json:"routing_key"`
+	MessageId       string     `json:"message_id"`
+	ContentType     string     `json:"content_type"`
+	ContentEncoding string     `json:"content_encoding"`
+	Headers         amqp.Table `json:"headers"`
+	Timestamp       time.Time  `json:"timestamp"`
+	Redelivered     bool       `json:"redelivered"`
+	Body            string     `json:"body"`
+}
+
+func newPeekMessage(d amqp.Delivery) PeekMessage {
+	return PeekMessage{
+		Exchange:        d.Exchange,
+		RoutingKey:      d.RoutingKey,
+		MessageId:       d.MessageId,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		Headers:         d.Headers,
+		Timestamp:       d.Timestamp,
+		Redelivered:     d.Redelivered,
+		Body:            peekBody(d.ContentEncoding, d.Body),
+	}
+}
+
+// peekBody 返回消息体用于查看, 压缩的消息解压后返回
+func peekBody(encoding string, body []byte) string {
+	if data, err := decompressBody(encoding, body, 0); err == nil && data != nil {
+		return string(data)
+	}
+	return string(body)
+}
+
+// deliveryToPublishing 复制消息的属性与内容, 用于重新发布
+func deliveryToPublishing(d amqp.Delivery) amqp.Publishing {
+	return amqp.Publishing{
+		Headers:         d.Headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    d.DeliveryMode,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		ReplyTo:         d.ReplyTo,
+		Expiration:      d.Expiration,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		UserId:          d.UserId,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
+}
+
+// InspectQueue 查看队列的消息数与消费者数
+func (r *RabbitMQ) InspectQueue(queue string) (QueueInfo, error) {
+	// 队列不存在时通道会被关闭, 使用临时通道
+	ch, err := r.GetChannel()
+	if err != nil {
+		return QueueInfo{}, err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueInspect(queue)
+	if err != nil {
+		return QueueInfo{}, err
+	}
+	return QueueInfo{Name: q.Name, Messages: q.Messages, Consumers: q.Consumers}, nil
+}
+
+// PurgeQueue 清空队列中待投递的消息, 返回清除的消息数
+func (r *RabbitMQ) PurgeQueue(queue string) (int, error) {
+	ch, err := r.GetChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	return ch.QueuePurge(queue, false)
+}
+
+// DeleteQueue 删除队列, 返回删除的消息数
+// ifUnused 为true时队列有消费者则不删除, ifEmpty 为true时队列有消息则不删除
+func (r *RabbitMQ) DeleteQueue(queue string, ifUnused, ifEmpty bool) (int, error) {
+	ch, err := r.GetChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	return ch.QueueDelete(queue, ifUnused, ifEmpty, false)
+}
+
+// MoveMessages 将 from 队列的消息移动到 to 队列, limit<=0 时移动全部消息
+// 最多移动开始时 from 队列中的消息数, 移动过程中新发布的消息不会被移动
+// 消息在 to 队列确认收到后才从 from 队列删除, 返回移动的消息数
+// to 队列不存在或消息无法路由时返回错误, 消息重新回到 from 队列
+// from 与 to 相同时返回 ErrMoveSameQueue
+func (r *RabbitMQ) MoveMessages(from, to string, limit int) (int, error) {
+	if from == to {
+		return 0, ErrMoveSameQueue
+	}
+
+	ch, err := r.GetChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	if _, err = ch.QueueDeclarePassive(to, true, false, false, false, nil); err != nil {
+		return 0, fmt.Errorf("target queue %s: %w", to, err)
+	}
+
+	q, err := ch.QueueInspect(from)
+	if err != nil {
+		return 0, err
+	}
+	limit = moveLimit(limit, q.Messages)
+
+	moved := 0
+	for moved < limit {
+		msg, ok, err := ch.Get(from, false)
+		if err != nil {
+			return moved, err
+		}
+		if !ok {
+			break
+		}
+
+		ctx, cancel := context.WithTimeout(r.cxt, adminPublishTimeout)
+		err = r.PublishMandatory(ctx, "", to, deliveryToPublishing(msg))
+		cancel()
+		if err != nil {
+			_ = msg.Nack(false, true)
+			return moved, err
+		}
+		if err = msg.Ack(false); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// moveLimit limit<=0 或超过队列中的消息数时只移动 depth 条消息
+func moveLimit(limit, depth int) int {
+	if limit <= 0 || limit > depth {
+		return depth
+	}
+	return limit
+}
+
+// PeekMessages 查看队首的 count 条消息而不消费
+// 消息取出后立即重新入队, 之后投递时 Redelivered 为 true
+func (r *RabbitMQ) PeekMessages(queue string, count int) ([]PeekMessage, error) {
+	ch, err := r.GetChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	msgs := make([]PeekMessage, 0, count)
+	var lastTag uint64
+	for i := 0; i < count; i++ {
+		msg, ok, err := ch.Get(queue, false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		lastTag = msg.DeliveryTag
+		msgs = append(msgs, newPeekMessage(msg))
+	}
+	if lastTag > 0 {
+		if err = ch.Nack(lastTag, true, true); err != nil {
+			return nil, err
+		}
+	}
+	return msgs, nil
+}
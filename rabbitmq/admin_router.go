@@ -0,0 +1,163 @@
+package rabbitmq
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qumogu/go-tools/httpserver"
+)
+
+const (
+	defaultPeekCount = 10
+	maxPeekCount     = 100
+)
+
+// AdminAuthFunc 管理接口鉴权, 返回错误时拒绝请求.
+type AdminAuthFunc func(c *gin.Context) error
+
+type MoveParam struct {
+	To    string `json:"to" form:"to" binding:"required"` // 目标队列
+	Limit int    `json:"limit" form:"limit"`              // 最多移动的消息数, 0 表示全部
+}
+
+type PeekParam struct {
+	Count int `form:"count"` // 查看的消息数, 默认10, 最大100
+}
+
+type DeleteQueueParam struct {
+	IfUnused bool `form:"if_unused"` // 队列有消费者时不删除
+	IfEmpty  bool `form:"if_empty"`  // 队列有消息时不删除
+}
+
+type queueAdminController struct {
+	admin QueueAdmin
+}
+
+// QueueAdminRoutes 注册队列管理接口, 包含清空、删除等危险操作, auth 不能为 nil.
+// It defines
+//
+//	GET:  /queues/:queue
+//	GET:  /queues/:queue/peek
+//	POST: /queues/:queue/purge
+//	POST: /queues/:queue/del
+//	POST: /queues/:queue/move
+func QueueAdminRoutes(group *gin.RouterGroup, admin QueueAdmin, auth AdminAuthFunc) {
+	if auth == nil {
+		panic("rabbitmq: QueueAdminRoutes requires an auth func")
+	}
+
+	ctl := &queueAdminController{admin: admin}
+
+	grp := group.Group("/queues")
+	grp.Use(adminAuth(auth))
+
+	grp.GET("/:queue", ctl.InspectController)
+	grp.GET("/:queue/peek", ctl.PeekController)
+	grp.POST("/:queue/purge", ctl.PurgeController)
+	grp.POST("/:queue/del", ctl.DeleteController)
+	grp.POST("/:queue/move", ctl.MoveController)
+}
+
+func adminAuth(auth AdminAuthFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := auth(c); err != nil {
+			httpserver.FailureWithHTTPStatus(c, http.StatusForbidden, ErrAdminAuthCodeKey, err.Error())
+			c.Abort()
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (ctl *queueAdminController) InspectController(c *gin.Context) {
+	info, err := ctl.admin.InspectQueue(c.Param("queue"))
+	if err != nil {
+		httpserver.Failure(c, FailureExit, err.Error())
+
+		return
+	}
+
+	httpserver.Success(c, info)
+}
+
+func (ctl *queueAdminController) PeekController(c *gin.Context) {
+	param := &PeekParam{}
+	if err := c.ShouldBindQuery(param); err != nil {
+		httpserver.Failure(c, ErrParamParseCodeKey, err.Error())
+
+		return
+	}
+
+	if param.Count <= 0 {
+		param.Count = defaultPeekCount
+	}
+
+	if param.Count > maxPeekCount {
+		param.Count = maxPeekCount
+	}
+
+	msgs, err := ctl.admin.PeekMessages(c.Param("queue"), param.Count)
+	if err != nil {
+		httpserver.Failure(c, FailureExit, err.Error())
+
+		return
+	}
+
+	httpserver.Success(c, msgs)
+}
+
+func (ctl *queueAdminController) PurgeController(c *gin.Context) {
+	n, err := ctl.admin.PurgeQueue(c.Param("queue"))
+	if err != nil {
+		httpserver.Failure(c, FailureExit, err.Error())
+
+		return
+	}
+
+	httpserver.Success(c, gin.H{"purged": n})
+}
+
+func (ctl *queueAdminController) DeleteController(c *gin.Context) {
+	param := &DeleteQueueParam{}
+	if err := c.ShouldBindQuery(param); err != nil {
+		httpserver.Failure(c, ErrParamParseCodeKey, err.Error())
+
+		return
+	}
+
+	n, err := ctl.admin.DeleteQueue(c.Param("queue"), param.IfUnused, param.IfEmpty)
+	if err != nil {
+		httpserver.Failure(c, FailureExit, err.Error())
+
+		return
+	}
+
+	httpserver.Success(c, gin.H{"deleted": n})
+}
+
+func (ctl *queueAdminController) MoveController(c *gin.Context) {
+	param := &MoveParam{}
+	if err := c.ShouldBind(param); err != nil {
+		httpserver.Failure(c, ErrParamParseCodeKey, err.Error())
+
+		return
+	}
+
+	n, err := ctl.admin.MoveMessages(c.Param("queue"), param.To, param.Limit)
+	if errors.Is(err, ErrMoveSameQueue) {
+		httpserver.FailureWithHTTPStatus(c, http.StatusBadRequest, ErrParamParseCodeKey, err.Error())
+
+		return
+	}
+
+	if err != nil {
+		httpserver.Failure(c, FailureExit, err.Error())
+
+		return
+	}
+
+	httpserver.Success(c, gin.H{"moved": n})
+}
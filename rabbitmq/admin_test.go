@@ -0,0 +1,139 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestQueueAdminRoutes(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareBindQueue("test.stuck", "", "")
+	_ = mq.DeclareBindQueue("test.retry", "", "")
+	for i := 0; i < 3; i++ {
+		_ = mq.Publish("", "test.stuck", i)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	QueueAdminRoutes(r.Group("/admin/mq"), mq, func(c *gin.Context) error {
+		if c.GetHeader("X-Admin-Token") != "secret" {
+			return errors.New("forbidden")
+		}
+
+		return nil
+	})
+
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Admin-Token", "secret")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		resp := map[string]interface{}{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+
+		return w.Code, resp
+	}
+
+	_, resp := do(http.MethodGet, "/admin/mq/queues/test.stuck", "")
+	if data := resp["data"].(map[string]interface{}); data["messages"] != float64(3) {
+		t.Error("unexpected inspect result:", resp)
+	}
+
+	_, resp = do(http.MethodGet, "/admin/mq/queues/test.stuck/peek?count=2", "")
+	if data := resp["data"].([]interface{}); len(data) != 2 {
+		t.Error("unexpected peek result:", resp)
+	}
+
+	_, resp = do(http.MethodPost, "/admin/mq/queues/test.stuck/move", `{"to":"test.retry","limit":2}`)
+	if data := resp["data"].(map[string]interface{}); data["moved"] != float64(2) {
+		t.Error("unexpected move result:", resp)
+	}
+
+	if code, _ := do(http.MethodPost, "/admin/mq/queues/test.stuck/move", `{"to":"test.stuck"}`); code != http.StatusBadRequest {
+		t.Error("move to the source queue should be rejected, got:", code)
+	}
+
+	_, resp = do(http.MethodPost, "/admin/mq/queues/test.stuck/purge", "")
+	if data := resp["data"].(map[string]interface{}); data["purged"] != float64(1) {
+		t.Error("unexpected purge result:", resp)
+	}
+
+	if info, _ := mq.InspectQueue("test.retry"); info.Messages != 2 {
+		t.Error("moved messages should be in target queue:", info)
+	}
+
+	_, resp = do(http.MethodPost, "/admin/mq/queues/test.retry/del?if_empty=true", "")
+	if resp["code"] == float64(0) {
+		t.Error("non-empty queue should not be deleted with if_empty")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/mq/queues/test.stuck", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Error("request without token should be rejected, got:", w.Code)
+	}
+}
+
+func TestQueueAdminRoutes_NilAuth(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("nil auth should panic")
+		}
+	}()
+
+	QueueAdminRoutes(gin.New().Group("/admin/mq"), mq, nil)
+}
+
+func TestMemoryBroker_MoveUnknownTarget(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareBindQueue("test.stuck", "", "")
+	_ = mq.Publish("", "test.stuck", 1)
+
+	if _, err := mq.MoveMessages("test.stuck", "not.exist", 0); !errors.Is(err, ErrMemQueueNotFound) {
+		t.Error("move to unknown queue should fail, got:", err)
+	}
+
+	if info, _ := mq.InspectQueue("test.stuck"); info.Messages != 1 {
+		t.Error("message should stay in source queue:", info)
+	}
+}
+
+func TestMemoryBroker_MoveSameQueue(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareBindQueue("test.stuck", "", "")
+	_ = mq.DeclareBindQueue("test.retry", "", "")
+	for i := 0; i < 3; i++ {
+		_ = mq.Publish("", "test.stuck", i)
+	}
+
+	if _, err := mq.MoveMessages("test.stuck", "test.stuck", 0); !errors.Is(err, ErrMoveSameQueue) {
+		t.Error("move to the source queue should fail, got:", err)
+	}
+
+	if info, _ := mq.InspectQueue("test.stuck"); info.Messages != 3 {
+		t.Error("messages should stay in source queue:", info)
+	}
+
+	// limit 超过队列中的消息数时只移动已有的消息
+	if n, err := mq.MoveMessages("test.stuck", "test.retry", 10); err != nil || n != 3 {
+		t.Errorf("moved = %d, err = %v, want 3", n, err)
+	}
+}
@@ -0,0 +1,235 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const defaultConfirmWindow = 256
+
+// BatchCallBackFunc 批量消费回调, 返回nil时整批确认, 返回错误时整批重新入队
+type BatchCallBackFunc func(ctx context.Context, ds []amqp.Delivery) error
+
+// BatchPublishError 批量发布中第一条失败的消息
+type BatchPublishError struct {
+	Index int // 失败消息在批次中的下标
+	Err   error
+}
+
+func (e *BatchPublishError) Error() string {
+	return fmt.Sprintf("publish batch message %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchPublishError) Unwrap() error {
+	return e.Err
+}
+
+// PublishBatch 以确认模式批量发布消息
+// 最多 ConfirmWindow 条消息同时等待确认, 窗口满时等待最早的确认, 全部确认后返回
+// 失败时返回 *BatchPublishError, 之前的消息已被确认, 之后的消息可能已发布
+func (r *RabbitMQ) PublishBatch(ctx context.Context, exchange, key string, msgs []interface{}) error {
+	window := r.cnf.ConfirmWindow
+	if window <= 0 {
+		window = defaultConfirmWindow
+	}
+
+	if err := r.waitUnblocked(ctx); err != nil {
+		return err
+	}
+
+	r.confirmLock.Lock()
+	defer r.confirmLock.Unlock()
+
+	ch, err := r.getConfirmChannel()
+	if err != nil {
+		return err
+	}
+
+	pending := make([]*amqp.DeferredConfirmation, 0, window)
+	first := 0 // pending[0] 对应的消息下标
+	wait := func() error {
+		ok, err := pending[0].WaitContext(ctx)
+		if err == nil && !ok {
+			err = ErrPublishNacked
+		}
+		if err != nil {
+			return &BatchPublishError{Index: first, Err: err}
+		}
+		pending = pending[1:]
+		first++
+		return nil
+	}
+
+	for i, msg := range msgs {
+		pub, err := newJSONPublishing(msg)
+		if err == nil {
+			injectTrace(ctx, &pub)
+			err = compressPublishing(&pub, r.cnf.Compression, r.cnf.CompressThreshold)
+		}
+		if err != nil {
+			return &BatchPublishError{Index: i, Err: err}
+		}
+		if len(pending) >= window {
+			if err = wait(); err != nil {
+				return err
+			}
+		}
+		dc, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, pub)
+		if err != nil {
+			return &BatchPublishError{Index: i, Err: err}
+		}
+		pending = append(pending, dc)
+	}
+	for len(pending) > 0 {
+		if err = wait(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConsumeBatch 批量消费, 收集 size 条消息或第一条消息等待 wait 后回调
+// 使用独立的通道, 回调成功后以 multiple=true 确认整批消息, 失败时整批重新入队
+func (r *RabbitMQ) ConsumeBatch(queue string, size int, wait time.Duration, fn BatchCallBackFunc) error {
+	if size <= 0 {
+		return errors.New("batch size should be positive")
+	}
+
+	ch, err := r.GetChannel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	// prefetch 小于批次大小时批次永远无法凑满
+	if size > r.prefetch {
+		if err = ch.Qos(size, 0, false); err != nil {
+			return err
+		}
+	}
+
+	msgChan, err := ch.Consume(queue, "", false, false, false, false, nil)
+	if err != nil {
+		fmt.Println("channel consume failed: ", err)
+		return err
+	}
+
+	batch := make([]amqp.Delivery, 0, size)
+	var timeout <-chan time.Time
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		last := batch[len(batch)-1]
+		if err := fn(r.cxt, batch); err == nil {
+			_ = last.Ack(true)
+		} else {
+			fmt.Println("batch callback function run error:", err)
+			_ = last.Nack(true, true)
+		}
+		batch = make([]amqp.Delivery, 0, size)
+		timeout = nil
+	}
+
+	for {
+		select {
+		case msg, ok := <-msgChan:
+			if !ok {
+				fmt.Println("mq channel is closed")
+				return amqp.ErrClosed
+			}
+			if err = r.decompress(&msg); err != nil {
+				fmt.Println("decompress message error:", err)
+				_ = msg.Reject(false)
+				continue
+			}
+			batch = append(batch, msg)
+			if len(batch) == 1 {
+				timeout = time.After(wait)
+			}
+			if len(batch) >= size {
+				flush()
+			}
+
+		case <-timeout:
+			flush()
+
+		case <-r.cxt.Done():
+			fmt.Println("service is closing")
+			return nil
+		}
+	}
+}
+
+// PublishBatch 批量发布消息.
+func (b *MemoryBroker) PublishBatch(ctx context.Context, exchange, key string, msgs []interface{}) error {
+	for i, msg := range msgs {
+		pub, err := newJSONPublishing(msg)
+		if err == nil {
+			err = b.PublishConfirm(ctx, exchange, key, pub)
+		}
+
+		if err != nil {
+			return &BatchPublishError{Index: i, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// ConsumeBatch 批量消费, 收集 size 条消息或第一条消息等待 wait 后回调.
+func (b *MemoryBroker) ConsumeBatch(queue string, size int, wait time.Duration, fn BatchCallBackFunc) error {
+	if size <= 0 {
+		return errors.New("batch size should be positive")
+	}
+
+	ch := newMemChannel(b, queue)
+
+	b.addConsumer(queue, 1)
+	defer b.addConsumer(queue, -1)
+
+	for {
+		first, err := ch.next(b.cxt)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+
+			return err
+		}
+
+		if err = DecompressDelivery(&first); err != nil {
+			_ = first.Reject(false)
+			continue
+		}
+
+		batch := []amqp.Delivery{first}
+
+		ctx, cancel := context.WithTimeout(b.cxt, wait)
+		for len(batch) < size {
+			d, err := ch.next(ctx)
+			if err != nil {
+				break
+			}
+
+			if err = DecompressDelivery(&d); err != nil {
+				_ = d.Reject(false)
+				continue
+			}
+
+			batch = append(batch, d)
+		}
+		cancel()
+
+		last := batch[len(batch)-1]
+		if err = fn(b.cxt, batch); err == nil {
+			_ = last.Ack(true)
+		} else {
+			_ = last.Nack(true, true)
+		}
+	}
+}
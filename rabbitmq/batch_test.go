@@ -0,0 +1,63 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestMemoryBroker_Batch(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareDirectEx("test.report")
+	_ = mq.DeclareBindQueue("test.report.ingest", "test.report", "report")
+
+	msgs := make([]interface{}, 0, 5)
+	for i := 0; i < 5; i++ {
+		msgs = append(msgs, i)
+	}
+	if err := mq.PublishBatch(context.Background(), "test.report", "report", msgs); err != nil {
+		t.Fatal(err)
+	}
+
+	var failed int32
+	sizes := make(chan int, 10)
+	go func() {
+		_ = mq.ConsumeBatch("test.report.ingest", 2, 20*time.Millisecond, func(ctx context.Context, ds []amqp.Delivery) error {
+			if atomic.CompareAndSwapInt32(&failed, 0, 1) {
+				return errors.New("fail first batch")
+			}
+			sizes <- len(ds)
+
+			return nil
+		})
+	}()
+
+	total := 0
+	for total < 5 {
+		select {
+		case n := <-sizes:
+			if n > 2 {
+				t.Fatal("batch larger than size:", n)
+			}
+			total += n
+		case <-time.After(time.Second):
+			t.Fatal("batches not delivered, got", total)
+		}
+	}
+
+	if info, _ := mq.InspectQueue("test.report.ingest"); info.Messages != 0 {
+		t.Error("all messages should be acked:", info)
+	}
+
+	err := mq.PublishBatch(context.Background(), "not.exist", "", msgs)
+	var batchErr *BatchPublishError
+	if !errors.As(err, &batchErr) || batchErr.Index != 0 {
+		t.Error("unexpected batch error:", err)
+	}
+}
@@ -0,0 +1,41 @@
+package rabbitmq
+
+import (
+	"context"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	_ Broker = (*RabbitMQ)(nil)
+	_ Broker = (*MemoryBroker)(nil)
+)
+
+// Broker 消息代理接口.
+// RabbitMQ 连接真实的 broker, MemoryBroker 为进程内实现, 供单元测试替换使用.
+type Broker interface {
+	Publish(exchange, key string, msg interface{}) error
+	PublishWithContext(ctx context.Context, exchange, key string, msg interface{}) error
+	PublishWithOptions(ctx context.Context, exchange, key string, msg interface{}, opts PublishOptions) error
+	PublishConfirm(ctx context.Context, exchange, key string, pub amqp.Publishing) error
+	PublishMandatory(ctx context.Context, exchange, key string, pub amqp.Publishing) error
+	PublishAfter(exchange, key string, msg interface{}, delay time.Duration) error
+	PublishAt(exchange, key string, msg interface{}, at time.Time) error
+	PublishBatch(ctx context.Context, exchange, key string, msgs []interface{}) error
+	Consume(queue string, fn ConsumeCallBackFunc) error
+	ConsumeDelivery(queue string, fn DeliveryCallBackFunc) error
+	ConsumeBatch(queue string, size int, wait time.Duration, fn BatchCallBackFunc) error
+	GetMsg(queue string) ([]byte, error)
+	SyncCallBackMsg(toExchange, toKey, msg string, useDefExchange bool, timeout int) ([]byte, error)
+
+	DeclareDirectEx(exchange string) error
+	DeclareFanoutEx(exchange string) error
+	DeclareTopicEx(exchange string) error
+	DeclareBindQueue(queue, exchange, key string) error
+	DeclareBindQueueWithArgs(queue, exchange, key string, args amqp.Table) error
+	DeclareQueue(queue, exchange, key string, opts QueueOptions) error
+	DeclareTmpQueue(tmpExchange, tmpQueue string) error
+
+	Close()
+}
@@ -0,0 +1,151 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+
+	defaultCompressThreshold = 64 * 1024
+	defaultMaxDecompressSize = 64 * 1024 * 1024
+)
+
+var ErrDecompressTooLarge = errors.New("decompressed message body exceeds limit")
+
+// zstd 的 EncodeAll/DecodeAll 可以并发使用, 解码器按解压大小上限缓存
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoders   sync.Map
+)
+
+func getZstdDecoder(max int) (*zstd.Decoder, error) {
+	if dec, ok := zstdDecoders.Load(max); ok {
+		return dec.(*zstd.Decoder), nil
+	}
+
+	// 编码器声明的窗口可达内容大小的2倍, 解码器的窗口又不能超过 MaxMemory,
+	// 因此按2倍上限限制内存, 解压后再按 max 检查大小
+	limit := 2 * uint64(max)
+	window := limit
+	if window < zstd.MinWindowSize {
+		window = zstd.MinWindowSize
+	}
+	if window > zstd.MaxWindowSize {
+		window = zstd.MaxWindowSize
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(limit), zstd.WithDecoderMaxWindow(window))
+	if err != nil {
+		return nil, err
+	}
+	actual, loaded := zstdDecoders.LoadOrStore(max, dec)
+	if loaded {
+		dec.Close()
+	}
+	return actual.(*zstd.Decoder), nil
+}
+
+// compressPublishing 消息体超过 threshold 时以 algo 压缩并设置 ContentEncoding
+// algo 为空或消息已设置 ContentEncoding 时不处理
+func compressPublishing(pub *amqp.Publishing, algo string, threshold int) error {
+	if algo == "" || pub.ContentEncoding != "" {
+		return nil
+	}
+	if threshold <= 0 {
+		threshold = defaultCompressThreshold
+	}
+	if len(pub.Body) <= threshold {
+		return nil
+	}
+
+	switch algo {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(pub.Body); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		pub.Body = buf.Bytes()
+	case CompressionZstd:
+		pub.Body = zstdEncoder.EncodeAll(pub.Body, make([]byte, 0, len(pub.Body)/2))
+	default:
+		return fmt.Errorf("unsupported compression: %s", algo)
+	}
+	pub.ContentEncoding = algo
+	return nil
+}
+
+// DecompressDelivery 按 ContentEncoding 解压消息体并清空 ContentEncoding
+// 未压缩或其他编码的消息不处理, 兼容旧的未压缩消息
+// 解压后超过64MB时返回 ErrDecompressTooLarge, RabbitMQ 使用 MaxDecompressSize 配置的上限
+func DecompressDelivery(d *amqp.Delivery) error {
+	return decompressDelivery(d, defaultMaxDecompressSize)
+}
+
+// decompress 按 MaxDecompressSize 解压消息体
+func (r *RabbitMQ) decompress(d *amqp.Delivery) error {
+	return decompressDelivery(d, r.cnf.MaxDecompressSize)
+}
+
+func decompressDelivery(d *amqp.Delivery, max int) error {
+	body, err := decompressBody(d.ContentEncoding, d.Body, max)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		d.Body = body
+		d.ContentEncoding = ""
+	}
+	return nil
+}
+
+// decompressBody 解压消息体, 不是支持的压缩编码时返回nil
+// 解压后超过 max 字节时返回 ErrDecompressTooLarge, 避免压缩炸弹耗尽内存, max<=0 时使用默认值
+func decompressBody(encoding string, body []byte, max int) ([]byte, error) {
+	if max <= 0 {
+		max = defaultMaxDecompressSize
+	}
+
+	var data []byte
+	switch encoding {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		if data, err = io.ReadAll(io.LimitReader(r, int64(max)+1)); err != nil {
+			return nil, err
+		}
+	case CompressionZstd:
+		dec, err := getZstdDecoder(max)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = dec.DecodeAll(body, nil); err != nil {
+			if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+				return nil, fmt.Errorf("%w: %v", ErrDecompressTooLarge, err)
+			}
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	if len(data) > max {
+		return nil, ErrDecompressTooLarge
+	}
+	return data, nil
+}
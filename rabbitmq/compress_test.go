@@ -0,0 +1,107 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestCompressPublishing(t *testing.T) {
+	body := []byte(strings.Repeat(`{"name":"report"}`, 100))
+
+	for _, algo := range []string{CompressionGzip, CompressionZstd} {
+		pub := amqp.Publishing{Body: body}
+		if err := compressPublishing(&pub, algo, 100); err != nil {
+			t.Fatal(err)
+		}
+		if pub.ContentEncoding != algo || len(pub.Body) >= len(body) {
+			t.Fatalf("%s: body should be compressed, encoding %q, size %d", algo, pub.ContentEncoding, len(pub.Body))
+		}
+
+		d := amqp.Delivery{ContentEncoding: pub.ContentEncoding, Body: pub.Body}
+		if err := DecompressDelivery(&d); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(d.Body, body) || d.ContentEncoding != "" {
+			t.Errorf("%s: decompressed body mismatch", algo)
+		}
+	}
+
+	small := amqp.Publishing{Body: []byte(`"small"`)}
+	_ = compressPublishing(&small, CompressionGzip, 100)
+	if small.ContentEncoding != "" {
+		t.Error("body below threshold should not be compressed")
+	}
+
+	if err := compressPublishing(&amqp.Publishing{Body: body}, "lz4", 100); err == nil {
+		t.Error("unsupported compression should fail")
+	}
+}
+
+func TestMemoryBroker_Compression(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+	mq.SetCompression(CompressionZstd, 100)
+
+	_ = mq.DeclareDirectEx("test.report")
+	_ = mq.DeclareBindQueue("test.report.q", "test.report", "report")
+
+	report := strings.Repeat("x", 1000)
+	_ = mq.Publish("test.report", "report", report)
+	// 旧的未压缩消息
+	_ = mq.PublishConfirm(context.Background(), "test.report", "report", amqp.Publishing{Body: []byte(`"plain"`)})
+	// 损坏的压缩消息被拒绝
+	_ = mq.PublishConfirm(context.Background(), "test.report", "report", amqp.Publishing{ContentEncoding: CompressionGzip, Body: []byte("bad")})
+
+	peek, _ := mq.PeekMessages("test.report.q", 1)
+	if len(peek) != 1 || peek[0].ContentEncoding != CompressionZstd || peek[0].Body != `"`+report+`"` {
+		t.Fatal("peek should show the decompressed body")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []string
+	go func() {
+		_ = mq.ConsumeDelivery("test.report.q", func(_ context.Context, d amqp.Delivery) error {
+			var s string
+			if err := json.Unmarshal(d.Body, &s); err != nil {
+				t.Error(err)
+			}
+			got = append(got, s)
+			if len(got) == 2 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	<-ctx.Done()
+
+	if len(got) != 2 || got[0] != report || got[1] != "plain" {
+		t.Errorf("unexpected messages: %d", len(got))
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	// 1MB 的重复内容压缩后只有几KB
+	body := bytes.Repeat([]byte("a"), 1<<20)
+
+	for _, algo := range []string{CompressionGzip, CompressionZstd} {
+		pub := amqp.Publishing{Body: body}
+		if err := compressPublishing(&pub, algo, 100); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := decompressBody(algo, pub.Body, 64*1024); !errors.Is(err, ErrDecompressTooLarge) {
+			t.Errorf("%s: want ErrDecompressTooLarge, got %v", algo, err)
+		}
+
+		data, err := decompressBody(algo, pub.Body, len(body))
+		if err != nil || len(data) != len(body) {
+			t.Errorf("%s: body within limit should be decompressed, got %d, %v", algo, len(data), err)
+		}
+	}
+}
@@ -1,19 +1,148 @@
 package rabbitmq
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net"
 	"net/url"
+	"os"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	HostSelectOrder  = "order"  // 按配置顺序尝试集群节点
+	HostSelectRandom = "random" // 随机顺序尝试集群节点
+
+	defaultHeartbeat = 10 * time.Second
+	defaultLocale    = "en_US"
 )
 
 type RabbitMQConfig struct {
 	Host          string
 	Port          string
+	Hosts         []string // 集群节点列表 host:port, 非空时忽略 Host 与 Port
+	HostSelect    string   // 连接与重连时节点的尝试顺序: order(默认) 或 random
 	Username      string
 	Password      string
 	VirtualHost   string
 	PrefetchCount int
+
+	TLS                bool   // 使用 amqps 连接
+	CACertFile         string // CA 证书文件, 为空时使用系统根证书
+	CertFile           string // 客户端证书文件, 双向认证时使用
+	KeyFile            string // 客户端私钥文件
+	ServerName         string // 校验服务端证书使用的主机名, 为空时使用连接的主机名
+	InsecureSkipVerify bool   // 不校验服务端证书, 仅用于测试环境
+
+	Heartbeat      time.Duration // 心跳间隔, 默认10s, 小于1s时使用服务端的配置
+	FrameSize      int           // 最大帧大小, 0 表示不限制
+	Locale         string        // 连接的 locale, 默认 en_US
+	ConnectionName string        // 客户端连接名称, 显示在管理界面中
+
+	DelayBuckets  []time.Duration // 延迟消息的分档, 默认 DefaultDelayBuckets
+	ConfirmWindow int             // 批量发布时同时等待确认的最大消息数, 默认256
+
+	PublishTimeout    time.Duration // Publish 等待发送完成的超时时间, 默认10s
+	BlockedMode       string        // 连接被 broker 阻塞时发布的处理方式: wait(默认)、fail、buffer
+	BlockedBufferSize int           // buffer 模式的本地缓冲大小, 默认1000
+
+	Compression       string // 消息体超过 CompressThreshold 时的压缩算法: gzip 或 zstd, 为空时不压缩
+	CompressThreshold int    // 压缩阈值(字节), 默认64KB
+	MaxDecompressSize int    // 消费时解压后消息体的最大字节数, 超过时消息被拒绝, 默认64MB
 }
 
+// GetUri 返回第一个节点的连接地址
 func (c *RabbitMQConfig) GetUri() string {
-	return fmt.Sprintf("amqp://%s:%s@%s:%s/%s", c.Username, url.QueryEscape(c.Password), c.Host, c.Port, c.VirtualHost)
+	return c.getUri(c.getHosts()[0])
+}
+
+// GetUris 按 HostSelect 返回所有节点的连接地址
+func (c *RabbitMQConfig) GetUris() []string {
+	hosts := c.getHosts()
+	if c.HostSelect == HostSelectRandom {
+		rand.Shuffle(len(hosts), func(i, j int) {
+			hosts[i], hosts[j] = hosts[j], hosts[i]
+		})
+	}
+
+	uris := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		uris = append(uris, c.getUri(host))
+	}
+	return uris
+}
+
+func (c *RabbitMQConfig) getUri(host string) string {
+	scheme := "amqp"
+	if c.TLS {
+		scheme = "amqps"
+	}
+	return fmt.Sprintf("%s://%s:%s@%s/%s", scheme, c.Username, url.QueryEscape(c.Password), host, c.VirtualHost)
+}
+
+func (c *RabbitMQConfig) getHosts() []string {
+	if len(c.Hosts) > 0 {
+		hosts := make([]string, len(c.Hosts))
+		copy(hosts, c.Hosts)
+		return hosts
+	}
+	return []string{net.JoinHostPort(c.Host, c.Port)}
+}
+
+// getAmqpConfig 生成 amqp.DialConfig 使用的配置
+func (c *RabbitMQConfig) getAmqpConfig() (amqp.Config, error) {
+	conf := amqp.Config{
+		Heartbeat: c.Heartbeat,
+		FrameSize: c.FrameSize,
+		Locale:    c.Locale,
+	}
+	if conf.Heartbeat == 0 {
+		conf.Heartbeat = defaultHeartbeat
+	}
+	if conf.Locale == "" {
+		conf.Locale = defaultLocale
+	}
+	if c.ConnectionName != "" {
+		conf.Properties = amqp.NewConnectionProperties()
+		conf.Properties["connection_name"] = c.ConnectionName
+	}
+	if c.TLS {
+		tlsConf, err := c.getTLSConfig()
+		if err != nil {
+			return conf, err
+		}
+		conf.TLSClientConfig = tlsConf
+	}
+	return conf, nil
+}
+
+func (c *RabbitMQConfig) getTLSConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CACertFile != "" {
+		caCert, err := os.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败,err:%w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("CA证书格式错误")
+		}
+		conf.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端证书失败,err:%w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
 }
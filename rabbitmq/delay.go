@@ -0,0 +1,175 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const DelayPrefix = "delay"
+
+var ErrDelayTooLong = errors.New("delay exceeds the largest delay bucket")
+
+// DefaultDelayBuckets 默认的延迟分档.
+var DefaultDelayBuckets = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+type delayDeclarer interface {
+	DeclareFanoutEx(exchange string) error
+	DeclareBindQueueWithArgs(queue, exchange, key string, args amqp.Table) error
+}
+
+// delayQueues 管理延迟队列.
+// 每个目标邮局的每个分档对应一个 fanout 邮局与一个 TTL 队列, 队列的 x-message-ttl 为分档时长,
+// 死信邮局为目标邮局; 消息的 Expiration 为实际延迟时间, 到期后以原路由key投递到目标邮局.
+// 延迟取大于等于它的最小分档, 因此队列数量有上限, 消息不会提前投递.
+// 同一分档内延迟较短的消息排在较长的消息之后时, 最多晚一个分档时长投递.
+type delayQueues struct {
+	buckets  []time.Duration
+	declared sync.Map
+}
+
+func newDelayQueues(buckets []time.Duration) *delayQueues {
+	if len(buckets) == 0 {
+		buckets = DefaultDelayBuckets
+	}
+
+	sorted := make([]time.Duration, len(buckets))
+	copy(sorted, buckets)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return &delayQueues{buckets: sorted}
+}
+
+// bucket 返回大于等于 delay 的最小分档.
+func (d *delayQueues) bucket(delay time.Duration) (time.Duration, error) {
+	for _, b := range d.buckets {
+		if delay <= b {
+			return b, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %v > %v", ErrDelayTooLong, delay, d.buckets[len(d.buckets)-1])
+}
+
+// route 声明并返回延迟 delay 投递到 exchange 时应发布到的邮局.
+func (d *delayQueues) route(b delayDeclarer, exchange string, delay time.Duration) (string, error) {
+	bucket, err := d.bucket(delay)
+	if err != nil {
+		return "", err
+	}
+
+	target := exchange
+	if target == "" {
+		target = "default"
+	}
+
+	name := fmt.Sprintf("%s.%s.%dms", DelayPrefix, target, bucket.Milliseconds())
+	if _, ok := d.declared.Load(name); ok {
+		return name, nil
+	}
+
+	if err = b.DeclareFanoutEx(name); err != nil {
+		return "", err
+	}
+
+	args := amqp.Table{
+		"x-message-ttl":          bucket.Milliseconds(),
+		"x-dead-letter-exchange": exchange,
+	}
+	if err = b.DeclareBindQueueWithArgs(name, name, "", args); err != nil {
+		return "", err
+	}
+
+	d.declared.Store(name, true)
+
+	return name, nil
+}
+
+// newDelayPublishing 生成延迟消息, delay 不足 1ms 时按 1ms 处理
+func newDelayPublishing(msg interface{}, delay time.Duration) (amqp.Publishing, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	ms := delay.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+
+	return amqp.Publishing{
+		ContentType:  "text/json",
+		Body:         body,
+		DeliveryMode: amqp.Persistent,
+		Expiration:   strconv.FormatInt(ms, 10),
+	}, nil
+}
+
+// PublishAfter 延迟 delay 后将消息投递到 exchange, 不依赖延迟插件
+func (r *RabbitMQ) PublishAfter(exchange, key string, msg interface{}, delay time.Duration) error {
+	if delay <= 0 {
+		return r.Publish(exchange, key, msg)
+	}
+
+	pub, err := newDelayPublishing(msg, delay)
+	if err != nil {
+		return err
+	}
+	delayExchange, err := r.delay.route(r, exchange, delay)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(r.cxt, r.publishTimeout())
+	defer cancel()
+	return r.publish(ctx, delayExchange, key, pub)
+}
+
+// PublishAt 在 at 时刻将消息投递到 exchange
+func (r *RabbitMQ) PublishAt(exchange, key string, msg interface{}, at time.Time) error {
+	return r.PublishAfter(exchange, key, msg, time.Until(at))
+}
+
+// PublishAfter 延迟 delay 后将消息投递到 exchange.
+func (b *MemoryBroker) PublishAfter(exchange, key string, msg interface{}, delay time.Duration) error {
+	if delay <= 0 {
+		return b.Publish(exchange, key, msg)
+	}
+
+	pub, err := newDelayPublishing(msg, delay)
+	if err != nil {
+		return err
+	}
+
+	delayExchange, err := b.delay.route(b, exchange, delay)
+	if err != nil {
+		return err
+	}
+
+	return b.publish(delayExchange, key, pub)
+}
+
+// PublishAt 在 at 时刻将消息投递到 exchange.
+func (b *MemoryBroker) PublishAt(exchange, key string, msg interface{}, at time.Time) error {
+	return b.PublishAfter(exchange, key, msg, time.Until(at))
+}
@@ -0,0 +1,56 @@
+package rabbitmq
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelayQueues_Bucket(t *testing.T) {
+	d := newDelayQueues([]time.Duration{time.Minute, time.Second, 10 * time.Second})
+	tests := []struct {
+		delay time.Duration
+		want  time.Duration
+	}{
+		{500 * time.Millisecond, time.Second},
+		{time.Second, time.Second},
+		{3 * time.Second, 10 * time.Second},
+		{30 * time.Second, time.Minute},
+	}
+	for _, tt := range tests {
+		if got, _ := d.bucket(tt.delay); got != tt.want {
+			t.Errorf("bucket(%v) = %v, want %v", tt.delay, got, tt.want)
+		}
+	}
+
+	if _, err := d.bucket(2 * time.Minute); !errors.Is(err, ErrDelayTooLong) {
+		t.Error("delay longer than the largest bucket should fail, got:", err)
+	}
+}
+
+func TestMemoryBroker_PublishAfter(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareDirectEx("test.order")
+	_ = mq.DeclareBindQueue("test.order.close", "test.order", "order.close")
+
+	if err := mq.PublishAfter("test.order", "order.close", "order-1", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mq.GetMsg("test.order.close"); err == nil {
+		t.Fatal("delayed message should not arrive immediately")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := mq.GetMsg("test.order.close"); err != nil {
+		t.Error("delayed message should arrive after delay:", err)
+	}
+
+	if err := mq.PublishAt("test.order", "order.close", "order-2", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mq.GetMsg("test.order.close"); err != nil {
+		t.Error("message scheduled in the past should be delivered immediately:", err)
+	}
+}
@@ -0,0 +1,7 @@
+package rabbitmq
+
+const (
+	FailureExit          = -5672
+	ErrParamParseCodeKey = 5601
+	ErrAdminAuthCodeKey  = 5602
+)
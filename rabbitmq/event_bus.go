@@ -0,0 +1,196 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/qumogu/go-tools/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// EventHandlerFunc 事件处理函数, payload 为注册时类型的指针, 如注册 DeviceReport{} 时为 *DeviceReport.
+type EventHandlerFunc func(ctx context.Context, key string, payload interface{}) error
+
+type eventHandler struct {
+	pattern     string
+	payloadType reflect.Type
+	fn          EventHandlerFunc
+}
+
+// EventBus 基于 topic 邮局的事件总线.
+// 服务为每种路由模式注册处理函数与消息类型, Run 时自动声明邮局、服务队列并绑定所有模式,
+// 收到消息后按注册顺序找到第一个匹配的处理函数, 解码后调用; 没有匹配的消息交给 fallback 处理.
+//
+//	bus := rabbitmq.NewEventBus(mq, "protocol.v1.event", "device-service.event")
+//	_ = bus.Handle("device.*.report", DeviceReport{}, onReport)
+//	go bus.Run()
+type EventBus struct {
+	broker   Broker
+	exchange string
+	queue    string
+
+	lock     sync.RWMutex
+	handlers []*eventHandler
+	bindings []string
+	fallback DeliveryCallBackFunc
+}
+
+func NewEventBus(broker Broker, exchange, queue string) *EventBus {
+	return &EventBus{
+		broker:   broker,
+		exchange: exchange,
+		queue:    queue,
+	}
+}
+
+// Handle 注册路由模式的处理函数, pattern 支持 * 与 #, payload 为消息解码的目标类型.
+func (b *EventBus) Handle(pattern string, payload interface{}, fn EventHandlerFunc) error {
+	if pattern == "" || fn == nil {
+		return errors.New("event pattern or handler is null")
+	}
+
+	payloadType := reflect.TypeOf(payload)
+	if payloadType == nil {
+		return fmt.Errorf("event %s payload type is null", pattern)
+	}
+
+	if payloadType.Kind() == reflect.Ptr {
+		payloadType = payloadType.Elem()
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.handlers = append(b.handlers, &eventHandler{
+		pattern:     pattern,
+		payloadType: payloadType,
+		fn:          fn,
+	})
+	b.bindings = append(b.bindings, pattern)
+
+	return nil
+}
+
+// Bind 绑定没有处理函数的路由模式, 这些消息交给 fallback 处理.
+func (b *EventBus) Bind(pattern string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.bindings = append(b.bindings, pattern)
+}
+
+// HandleFallback 设置没有匹配处理函数时的回调, 未设置时丢弃这类消息.
+func (b *EventBus) HandleFallback(fn DeliveryCallBackFunc) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.fallback = fn
+}
+
+// Publish 发布事件到总线的邮局.
+func (b *EventBus) Publish(key string, payload interface{}) error {
+	return b.broker.Publish(b.exchange, key, payload)
+}
+
+// Declare 声明 topic 邮局与服务队列, 并绑定所有已注册的路由模式.
+func (b *EventBus) Declare() error {
+	err := b.broker.DeclareTopicEx(b.exchange)
+	if err != nil {
+		return err
+	}
+
+	b.lock.RLock()
+	bindings := make([]string, len(b.bindings))
+	copy(bindings, b.bindings)
+	b.lock.RUnlock()
+
+	if len(bindings) == 0 {
+		return b.broker.DeclareBindQueue(b.queue, "", "")
+	}
+
+	for _, pattern := range bindings {
+		if err = b.broker.DeclareBindQueue(b.queue, b.exchange, pattern); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run 声明队列并开始消费, 直到 broker 关闭.
+func (b *EventBus) Run() error {
+	if err := b.Declare(); err != nil {
+		return err
+	}
+
+	return b.broker.ConsumeDelivery(b.queue, b.Dispatch)
+}
+
+// Dispatch 将消息分发给匹配的处理函数.
+// 消息体无法解码为事件类型时重试也无法处理, 返回 Discard 包装的错误, 消息被拒绝并进入死信队列.
+func (b *EventBus) Dispatch(ctx context.Context, d amqp.Delivery) error {
+	b.lock.RLock()
+	handler := b.match(d.RoutingKey)
+	fallback := b.fallback
+	b.lock.RUnlock()
+
+	if handler == nil {
+		if fallback == nil {
+			logger.WithContext(ctx).Warnw("event bus drop unmatched message", "routing_key", d.RoutingKey, "message_id", d.MessageId)
+			return nil
+		}
+
+		return fallback(ctx, d)
+	}
+
+	payload := reflect.New(handler.payloadType).Interface()
+	if err := json.Unmarshal(d.Body, payload); err != nil {
+		err = fmt.Errorf("decode event %s to %s: %w", d.RoutingKey, handler.payloadType, err)
+		logger.WithContext(ctx).Errorw("event bus reject malformed message", "message_id", d.MessageId, "error", err)
+
+		return Discard(err)
+	}
+
+	return handler.fn(ctx, d.RoutingKey, payload)
+}
+
+func (b *EventBus) match(key string) *eventHandler {
+	for _, h := range b.handlers {
+		if matchTopic(h.pattern, key) {
+			return h
+		}
+	}
+
+	return nil
+}
+
+// matchTopic 判断路由key是否匹配 topic 绑定模式, * 匹配一个单词, # 匹配零个或多个单词.
+func matchTopic(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchWords(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+
+		return false
+	case "*":
+		return len(words) > 0 && matchWords(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchWords(pattern[1:], words[1:])
+	}
+}
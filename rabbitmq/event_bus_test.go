@@ -0,0 +1,78 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type testDeviceReport struct {
+	DeviceID string  `json:"device_id"`
+	Value    float64 `json:"value"`
+}
+
+func TestEventBus(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	reports := make(chan *testDeviceReport, 1)
+	unmatched := make(chan string, 1)
+
+	bus := NewEventBus(mq, "test.event", "test.event.service")
+	err := bus.Handle("device.*.report", testDeviceReport{}, func(ctx context.Context, key string, payload interface{}) error {
+		reports <- payload.(*testDeviceReport)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus.Bind("device.#")
+	bus.HandleFallback(func(ctx context.Context, d amqp.Delivery) error {
+		unmatched <- d.RoutingKey
+		return nil
+	})
+	if err = bus.Declare(); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = bus.Run() }()
+
+	_ = bus.Publish("device.101.report", testDeviceReport{DeviceID: "101", Value: 3.5})
+	select {
+	case r := <-reports:
+		if r.DeviceID != "101" || r.Value != 3.5 {
+			t.Errorf("unexpected payload %+v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("report handler not called")
+	}
+
+	_ = bus.Publish("device.101.alarm", "high")
+	select {
+	case key := <-unmatched:
+		if key != "device.101.alarm" {
+			t.Error("unexpected fallback key:", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("fallback handler not called")
+	}
+}
+
+func TestEventBus_DispatchMalformed(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	bus := NewEventBus(mq, "test.event", "test.event.service")
+	_ = bus.Handle("device.*.report", testDeviceReport{}, func(ctx context.Context, key string, payload interface{}) error {
+		return nil
+	})
+
+	err := bus.Dispatch(context.Background(), amqp.Delivery{RoutingKey: "device.1.report", Body: []byte("not json")})
+
+	var se *settleError
+	if !errors.As(err, &se) || se.requeue {
+		t.Errorf("malformed message should be discarded, got %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	BlockedModeWait   = "wait"   // 等待解除阻塞, 直到 ctx 结束
+	BlockedModeFail   = "fail"   // 立即返回 ErrConnectionBlocked
+	BlockedModeBuffer = "buffer" // 写入本地缓冲, 解除阻塞后发送, 缓冲满时返回 ErrPublishBufferFull
+
+	defaultPublishTimeout    = 10 * time.Second
+	defaultBlockedBufferSize = 1000
+)
+
+var (
+	ErrConnectionBlocked = errors.New("rabbitmq connection is blocked by broker")
+	ErrPublishBufferFull = errors.New("rabbitmq publish buffer is full")
+)
+
+// FlowState 连接的流控状态
+type FlowState struct {
+	Blocked    bool   `json:"blocked"`     // 连接被 broker 阻塞, 通常是内存或磁盘告警
+	Reason     string `json:"reason"`      // 阻塞原因
+	FlowPaused bool   `json:"flow_paused"` // 通道被 channel.flow 暂停
+	Buffered   int    `json:"buffered"`    // buffer 模式下本地缓冲的消息数
+}
+
+type bufferedPub struct {
+	exchange string
+	key      string
+	pub      amqp.Publishing
+}
+
+// flowControl 记录 broker 的阻塞状态, 解除阻塞时关闭 unblocked 通知等待者
+type flowControl struct {
+	lock       sync.RWMutex
+	blocked    bool
+	reason     string
+	flowPaused bool
+	unblocked  chan struct{}
+}
+
+func newFlowControl() *flowControl {
+	unblocked := make(chan struct{})
+	close(unblocked)
+	return &flowControl{unblocked: unblocked}
+}
+
+func (f *flowControl) setBlocked(blocked bool, reason string) {
+	f.update(func() {
+		f.blocked = blocked
+		f.reason = reason
+	})
+}
+
+func (f *flowControl) setFlowPaused(paused bool) {
+	f.update(func() {
+		f.flowPaused = paused
+	})
+}
+
+func (f *flowControl) reset() {
+	f.update(func() {
+		f.blocked = false
+		f.reason = ""
+		f.flowPaused = false
+	})
+}
+
+func (f *flowControl) update(fn func()) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	wasBlocked := f.blocked || f.flowPaused
+	fn()
+	isBlocked := f.blocked || f.flowPaused
+
+	if isBlocked && !wasBlocked {
+		f.unblocked = make(chan struct{})
+	}
+	if !isBlocked && wasBlocked {
+		close(f.unblocked)
+	}
+}
+
+// wait 返回当前是否阻塞以及解除阻塞的通知
+func (f *flowControl) wait() (bool, <-chan struct{}) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.blocked || f.flowPaused, f.unblocked
+}
+
+// watchFlow 订阅连接的阻塞通知与通道的流控通知, 连接关闭后退出
+func (r *RabbitMQ) watchFlow(conn *amqp.Connection, ch *amqp.Channel) {
+	blockings := conn.NotifyBlocked(make(chan amqp.Blocking, 1))
+	flows := ch.NotifyFlow(make(chan bool, 1))
+	r.flow.reset()
+
+	go func() {
+		for {
+			select {
+			case b, ok := <-blockings:
+				if !ok {
+					// 连接已关闭, 重连后重新订阅
+					r.flow.reset()
+					return
+				}
+				if b.Active {
+					fmt.Println("rabbitmq connection blocked:", b.Reason)
+				} else {
+					fmt.Println("rabbitmq connection unblocked")
+				}
+				r.flow.setBlocked(b.Active, b.Reason)
+			case active, ok := <-flows:
+				if !ok {
+					flows = nil
+					continue
+				}
+				r.flow.setFlowPaused(!active)
+			case <-r.cxt.Done():
+				return
+			}
+		}
+	}()
+}
+
+// FlowState 返回当前的流控状态
+func (r *RabbitMQ) FlowState() FlowState {
+	r.flow.lock.RLock()
+	defer r.flow.lock.RUnlock()
+	return FlowState{
+		Blocked:    r.flow.blocked,
+		Reason:     r.flow.reason,
+		FlowPaused: r.flow.flowPaused,
+		Buffered:   len(r.pubBuffer),
+	}
+}
+
+// IsBlocked 连接是否被 broker 阻塞或被流控暂停
+func (r *RabbitMQ) IsBlocked() bool {
+	blocked, _ := r.flow.wait()
+	return blocked
+}
+
+// waitUnblocked 等待解除阻塞, BlockedModeFail 时立即返回错误
+func (r *RabbitMQ) waitUnblocked(ctx context.Context) error {
+	blocked, unblocked := r.flow.wait()
+	if !blocked {
+		return nil
+	}
+	if r.cnf.BlockedMode == BlockedModeFail {
+		return ErrConnectionBlocked
+	}
+	select {
+	case <-unblocked:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PublishWithContext 发布消息, ctx 结束时返回而不会一直等待被阻塞的连接
+func (r *RabbitMQ) PublishWithContext(ctx context.Context, exchange, key string, msg interface{}) error {
+	pub, err := newJSONPublishing(msg)
+	if err != nil {
+		fmt.Println("rabbitmq publish json marshal error:", err)
+		return err
+	}
+	return r.publish(ctx, exchange, key, pub)
+}
+
+// publish 按 BlockedMode 处理阻塞状态后在默认通道上发布消息
+func (r *RabbitMQ) publish(ctx context.Context, exchange, key string, pub amqp.Publishing) error {
+	injectTrace(ctx, &pub)
+	if err := compressPublishing(&pub, r.cnf.Compression, r.cnf.CompressThreshold); err != nil {
+		return err
+	}
+
+	if r.cnf.BlockedMode == BlockedModeBuffer {
+		// 缓冲中还有消息时也写入缓冲, 保证发送顺序
+		if !r.IsBlocked() && len(r.pubBuffer) == 0 {
+			err := r.send(ctx, exchange, key, pub)
+			if !errors.Is(err, ErrConnectionBlocked) {
+				return err
+			}
+		}
+		select {
+		case r.pubBuffer <- bufferedPub{exchange: exchange, key: key, pub: pub}:
+			return nil
+		default:
+			return ErrPublishBufferFull
+		}
+	}
+
+	for {
+		if err := r.waitUnblocked(ctx); err != nil {
+			return err
+		}
+		// 等待结束后又被阻塞时重新等待, BlockedModeFail 时由 waitUnblocked 返回错误
+		if err := r.send(ctx, exchange, key, pub); !errors.Is(err, ErrConnectionBlocked) {
+			return err
+		}
+	}
+}
+
+// send 在默认通道上发送消息, 连接被阻塞时返回 ErrConnectionBlocked 而不写入
+// amqp091 写入被阻塞的连接时不响应 ctx, 因此只在未阻塞时同步写入, ctx 结束后不会再发出消息
+func (r *RabbitMQ) send(ctx context.Context, exchange, key string, pub amqp.Publishing) error {
+	if blocked, _ := r.flow.wait(); blocked {
+		return ErrConnectionBlocked
+	}
+	return r.defChan.PublishWithContext(ctx, exchange, key, false, false, pub)
+}
+
+// drainBuffer 解除阻塞后按顺序发送缓冲的消息
+func (r *RabbitMQ) drainBuffer() {
+	for {
+		select {
+		case p := <-r.pubBuffer:
+			for {
+				_, unblocked := r.flow.wait()
+				select {
+				case <-unblocked:
+				case <-r.cxt.Done():
+					return
+				}
+				ctx, cancel := context.WithTimeout(r.cxt, r.publishTimeout())
+				err := r.send(ctx, p.exchange, p.key, p.pub)
+				cancel()
+				// 发送前又被阻塞时等待后重试, 保证发送顺序
+				if errors.Is(err, ErrConnectionBlocked) {
+					continue
+				}
+				if err != nil {
+					fmt.Println("rabbitmq publish buffered message error:", err)
+				}
+				break
+			}
+		case <-r.cxt.Done():
+			return
+		}
+	}
+}
+
+func (r *RabbitMQ) publishTimeout() time.Duration {
+	if r.cnf.PublishTimeout > 0 {
+		return r.cnf.PublishTimeout
+	}
+	return defaultPublishTimeout
+}
+
+// PublishWithContext 发布消息.
+func (b *MemoryBroker) PublishWithContext(ctx context.Context, exchange, key string, msg interface{}) error {
+	return b.PublishWithOptions(ctx, exchange, key, msg, PublishOptions{})
+}
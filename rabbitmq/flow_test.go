@@ -0,0 +1,87 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestFlowControl(t *testing.T) {
+	f := newFlowControl()
+	if blocked, _ := f.wait(); blocked {
+		t.Fatal("new flow control should not be blocked")
+	}
+
+	f.setBlocked(true, "low on memory")
+	blocked, unblocked := f.wait()
+	if !blocked {
+		t.Fatal("should be blocked")
+	}
+
+	// 连接解除阻塞但通道仍被暂停
+	f.setFlowPaused(true)
+	f.setBlocked(false, "")
+	select {
+	case <-unblocked:
+		t.Fatal("should still be paused by channel flow")
+	default:
+	}
+
+	f.setFlowPaused(false)
+	select {
+	case <-unblocked:
+	case <-time.After(time.Second):
+		t.Fatal("waiters should be notified after unblocked")
+	}
+}
+
+func TestWaitUnblocked(t *testing.T) {
+	r := &RabbitMQ{cnf: &RabbitMQConfig{}, flow: newFlowControl()}
+	r.flow.setBlocked(true, "disk alarm")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.waitUnblocked(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want deadline exceeded, got %v", err)
+	}
+
+	r.cnf.BlockedMode = BlockedModeFail
+	if err := r.waitUnblocked(context.Background()); !errors.Is(err, ErrConnectionBlocked) {
+		t.Fatalf("want ErrConnectionBlocked, got %v", err)
+	}
+
+	r.cnf.BlockedMode = BlockedModeWait
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		r.flow.setBlocked(false, "")
+	}()
+	if err := r.waitUnblocked(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishBlocked(t *testing.T) {
+	// defChan 为 nil, 阻塞时写入会 panic, 以此验证不会写入被阻塞的连接
+	r := &RabbitMQ{cnf: &RabbitMQConfig{BlockedMode: BlockedModeFail}, flow: newFlowControl()}
+	r.flow.setBlocked(true, "memory alarm")
+
+	if err := r.send(context.Background(), "", "test", amqp.Publishing{}); !errors.Is(err, ErrConnectionBlocked) {
+		t.Fatalf("send on blocked connection should fail fast, got %v", err)
+	}
+
+	if err := r.publish(context.Background(), "", "test", amqp.Publishing{}); !errors.Is(err, ErrConnectionBlocked) {
+		t.Fatalf("want ErrConnectionBlocked, got %v", err)
+	}
+
+	r.cnf.BlockedMode = BlockedModeBuffer
+	r.pubBuffer = make(chan bufferedPub, 1)
+	if err := r.publish(context.Background(), "", "test", amqp.Publishing{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.publish(context.Background(), "", "test", amqp.Publishing{}); !errors.Is(err, ErrPublishBufferFull) {
+		t.Fatalf("want ErrPublishBufferFull, got %v", err)
+	}
+}
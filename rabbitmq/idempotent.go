@@ -0,0 +1,137 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qumogu/go-tools/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// dedupRequeueDelay 其他消费者正在处理时, 等待后再重新入队, 避免消息在队列中快速循环
+const dedupRequeueDelay = time.Second
+
+// DedupState 去重记录的状态.
+type DedupState int
+
+const (
+	DedupNew        DedupState = iota // 首次出现, 当前消费者获得处理权
+	DedupProcessing                   // 其他消费者正在处理
+	DedupDone                         // 已处理完成
+)
+
+var (
+	ErrDedupKeyEmpty   = errors.New("dedup key is empty")
+	ErrDedupProcessing = errors.New("message is being processed by another consumer")
+	ErrDedupNotOwner   = errors.New("dedup claim is owned by another consumer")
+)
+
+// DedupStore 记录已处理的消息, 实现需保证 Claim 的原子性.
+// mongostore.DedupStore 为基于 mongo 的实现.
+type DedupStore interface {
+	// Claim 尝试获取 key 的处理权, 仅返回 DedupNew 时由调用方处理消息, token 标识本次获得的处理权.
+	Claim(ctx context.Context, key string) (state DedupState, token string, err error)
+	// Complete 标记 key 已处理完成, 处理权已被其他消费者接管时返回 ErrDedupNotOwner.
+	Complete(ctx context.Context, key, token string) error
+	// Release 处理失败时释放处理权, 重新投递的消息可以再次处理, 处理权已被接管时返回 ErrDedupNotOwner.
+	Release(ctx context.Context, key, token string) error
+}
+
+// DedupKeyFunc 从消息中提取去重key.
+type DedupKeyFunc func(d amqp.Delivery) (string, error)
+
+// MessageIDKey 使用消息的 MessageId 作为去重key.
+func MessageIDKey(d amqp.Delivery) (string, error) {
+	if d.MessageId == "" {
+		return "", ErrDedupKeyEmpty
+	}
+
+	return d.MessageId, nil
+}
+
+// PayloadKey 使用 json 消息体中指定字段值的哈希作为去重key, 未指定字段时对整个消息体哈希.
+func PayloadKey(fields ...string) DedupKeyFunc {
+	return func(d amqp.Delivery) (string, error) {
+		h := sha256.New()
+
+		if len(fields) == 0 {
+			h.Write(d.Body)
+
+			return hex.EncodeToString(h.Sum(nil)), nil
+		}
+
+		payload := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(d.Body, &payload); err != nil {
+			return "", err
+		}
+
+		for _, field := range fields {
+			v, ok := payload[field]
+			if !ok {
+				return "", fmt.Errorf("%w: field %s not found", ErrDedupKeyEmpty, field)
+			}
+
+			h.Write([]byte(field))
+			h.Write([]byte{0})
+			h.Write(v)
+			h.Write([]byte{0})
+		}
+
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+}
+
+// Idempotent 包装消费回调, 相同key的消息只处理一次.
+// 已处理过的消息直接确认; 其他消费者正在处理时等待片刻后重新入队(Requeue(ErrDedupProcessing));
+// 无法提取key的消息被拒绝(Discard), 配置了死信时进入死信队列;
+// 回调失败时释放处理权, 消息重新投递后可再次处理.
+//
+//	mq.ConsumeDelivery(queue, rabbitmq.Idempotent(store, rabbitmq.MessageIDKey, handler))
+func Idempotent(store DedupStore, keyFn DedupKeyFunc, fn DeliveryCallBackFunc) DeliveryCallBackFunc {
+	return func(ctx context.Context, d amqp.Delivery) error {
+		key, err := keyFn(d)
+		if err != nil {
+			return Discard(err)
+		}
+
+		state, token, err := store.Claim(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		switch state {
+		case DedupDone:
+			return nil
+		case DedupProcessing:
+			select {
+			case <-time.After(dedupRequeueDelay):
+			case <-ctx.Done():
+			}
+
+			return Requeue(ErrDedupProcessing)
+		}
+
+		if err = fn(ctx, d); err != nil {
+			if releaseErr := store.Release(ctx, key, token); releaseErr != nil {
+				logger.WithContext(ctx).Warnw("release dedup key error", "key", key, "error", releaseErr)
+			}
+
+			return err
+		}
+
+		err = store.Complete(ctx, key, token)
+		if errors.Is(err, ErrDedupNotOwner) {
+			// 租约过期后已被其他消费者接管, 由它标记完成
+			logger.WithContext(ctx).Warnw("dedup claim taken over before complete", "key", key)
+
+			return nil
+		}
+
+		return err
+	}
+}
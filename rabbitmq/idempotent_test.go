@@ -0,0 +1,133 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type mapDedupStore struct {
+	mu     sync.Mutex
+	state  map[string]DedupState
+	owners map[string]string
+	seq    int
+}
+
+func (s *mapDedupStore) Claim(_ context.Context, key string) (DedupState, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, ok := s.state[key]; ok {
+		return state, "", nil
+	}
+	s.seq++
+	s.state[key] = DedupProcessing
+	s.owners[key] = strconv.Itoa(s.seq)
+
+	return DedupNew, s.owners[key], nil
+}
+
+func (s *mapDedupStore) Complete(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state[key] != DedupProcessing || s.owners[key] != token {
+		return ErrDedupNotOwner
+	}
+	s.state[key] = DedupDone
+
+	return nil
+}
+
+func (s *mapDedupStore) Release(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state[key] != DedupProcessing || s.owners[key] != token {
+		return ErrDedupNotOwner
+	}
+	delete(s.state, key)
+
+	return nil
+}
+
+func TestIdempotent(t *testing.T) {
+	store := &mapDedupStore{state: map[string]DedupState{}, owners: map[string]string{}}
+	calls := 0
+	fail := true
+	fn := Idempotent(store, MessageIDKey, func(ctx context.Context, d amqp.Delivery) error {
+		calls++
+		if fail {
+			return errors.New("handler failed")
+		}
+
+		return nil
+	})
+
+	d := amqp.Delivery{MessageId: "msg-1", Body: []byte(`{"order_id":1}`)}
+	if err := fn(context.Background(), d); err == nil {
+		t.Fatal("handler error should be returned")
+	}
+
+	fail = false
+	for i := 0; i < 3; i++ {
+		if err := fn(context.Background(), d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("handler calls = %d, want 2", calls)
+	}
+
+	// ctx 已结束时不等待直接重新入队
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	store.state["msg-2"] = DedupProcessing
+	err := fn(ctx, amqp.Delivery{MessageId: "msg-2"})
+	var se *settleError
+	if !errors.Is(err, ErrDedupProcessing) || !errors.As(err, &se) || !se.requeue {
+		t.Error("in-progress message should be requeued, got:", err)
+	}
+
+	err = fn(context.Background(), amqp.Delivery{})
+	if !errors.Is(err, ErrDedupKeyEmpty) || !errors.As(err, &se) || se.requeue {
+		t.Error("message without key should be discarded, got:", err)
+	}
+}
+
+func TestIdempotent_TakenOver(t *testing.T) {
+	store := &mapDedupStore{state: map[string]DedupState{}, owners: map[string]string{}}
+	fn := Idempotent(store, MessageIDKey, func(ctx context.Context, d amqp.Delivery) error {
+		// 处理超时, 租约过期后被其他消费者接管
+		store.owners[d.MessageId] = "other"
+
+		return nil
+	})
+
+	if err := fn(context.Background(), amqp.Delivery{MessageId: "msg-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.state["msg-1"] != DedupProcessing {
+		t.Error("late complete should not mark the new owner's claim done")
+	}
+}
+
+func TestPayloadKey(t *testing.T) {
+	keyFn := PayloadKey("order_id")
+	k1, _ := keyFn(amqp.Delivery{Body: []byte(`{"order_id":1,"ts":1}`)})
+	k2, _ := keyFn(amqp.Delivery{Body: []byte(`{"order_id":1,"ts":2}`)})
+	k3, _ := keyFn(amqp.Delivery{Body: []byte(`{"order_id":2,"ts":1}`)})
+	if k1 != k2 || k1 == k3 {
+		t.Errorf("unexpected keys %s %s %s", k1, k2, k3)
+	}
+
+	if _, err := keyFn(amqp.Delivery{Body: []byte(`{"id":1}`)}); err == nil {
+		t.Error("missing field should fail")
+	}
+}
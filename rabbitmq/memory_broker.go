@@ -0,0 +1,894 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	ErrMemExchangeNotFound = errors.New("memory broker: exchange not found")
+	ErrMemQueueNotFound    = errors.New("memory broker: queue not found")
+	ErrMemUnknownTag       = errors.New("memory broker: unknown delivery tag")
+)
+
+const maxPriority = 255
+
+// MemoryBroker 进程内的消息代理, 用于单元测试中替换 RabbitMQ.
+// 支持 direct、fanout、topic 路由, ack/nack 与重新入队, 队列 TTL(x-message-ttl)、
+// 消息 TTL(Expiration)、优先级队列(x-max-priority) 以及死信(x-dead-letter-exchange/x-dead-letter-routing-key).
+// 消息不做持久化.
+type MemoryBroker struct {
+	mu        sync.Mutex
+	exchanges map[string]*memExchange
+	queues    map[string]*memQueue
+	delay     *delayQueues
+	compress  string
+	threshold int
+	cxt       context.Context
+	cancel    context.CancelFunc
+}
+
+type memExchange struct {
+	kind     string
+	bindings []memBinding
+}
+
+type memBinding struct {
+	queue string
+	key   string
+}
+
+type memQueue struct {
+	name      string
+	ttl       time.Duration // 0 表示不过期
+	dlx       string
+	dlKey     string
+	hasDLX    bool
+	priority  uint8 // x-max-priority, 0 表示不是优先级队列
+	msgs      []*memMessage
+	consumers int
+	ready     chan struct{} // 有新消息时通知消费者
+}
+
+type memMessage struct {
+	pub         amqp.Publishing
+	exchange    string
+	key         string
+	expireAt    time.Time // 零值表示不过期
+	redelivered bool
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	cxt, cancel := context.WithCancel(context.Background())
+
+	return &MemoryBroker{
+		exchanges: make(map[string]*memExchange),
+		queues:    make(map[string]*memQueue),
+		delay:     newDelayQueues(nil),
+		cxt:       cxt,
+		cancel:    cancel,
+	}
+}
+
+// Publish 发布消息, 与 RabbitMQ.Publish 一样以 json 编码.
+func (b *MemoryBroker) Publish(exchange, key string, msg interface{}) error {
+	pub, err := newJSONPublishing(msg)
+	if err != nil {
+		return err
+	}
+
+	return b.publish(exchange, key, pub)
+}
+
+// PublishConfirm 发布消息, 路由完成即视为确认.
+func (b *MemoryBroker) PublishConfirm(ctx context.Context, exchange, key string, pub amqp.Publishing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	injectTrace(ctx, &pub)
+
+	return b.publish(exchange, key, pub)
+}
+
+// PublishMandatory 发布消息, 没有队列接收时返回 ErrPublishUnroutable.
+func (b *MemoryBroker) PublishMandatory(ctx context.Context, exchange, key string, pub amqp.Publishing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	injectTrace(ctx, &pub)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	queues, err := b.route(exchange, key)
+	if err != nil {
+		return err
+	}
+
+	if len(queues) == 0 {
+		return fmt.Errorf("%w: exchange %q key %q", ErrPublishUnroutable, exchange, key)
+	}
+
+	if err = compressPublishing(&pub, b.compress, b.threshold); err != nil {
+		return err
+	}
+
+	return b.publishLocked(exchange, key, pub)
+}
+
+// SetCompression 设置消息压缩, 对应 RabbitMQConfig 的 Compression 与 CompressThreshold.
+func (b *MemoryBroker) SetCompression(algo string, threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.compress = algo
+	b.threshold = threshold
+}
+
+func (b *MemoryBroker) publish(exchange, key string, pub amqp.Publishing) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := compressPublishing(&pub, b.compress, b.threshold); err != nil {
+		return err
+	}
+
+	return b.publishLocked(exchange, key, pub)
+}
+
+func (b *MemoryBroker) publishLocked(exchange, key string, pub amqp.Publishing) error {
+	queues, err := b.route(exchange, key)
+	if err != nil {
+		return err
+	}
+
+	for _, q := range queues {
+		msg := &memMessage{
+			pub:      pub,
+			exchange: exchange,
+			key:      key,
+		}
+
+		ttl := q.ttl
+		if pub.Expiration != "" {
+			ms, err := strconv.ParseInt(pub.Expiration, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid expiration %q: %w", pub.Expiration, err)
+			}
+
+			if d := time.Duration(ms) * time.Millisecond; ttl == 0 || d < ttl {
+				ttl = d
+			}
+		}
+
+		if ttl > 0 {
+			msg.expireAt = time.Now().Add(ttl)
+			name := q.name
+			time.AfterFunc(ttl, func() { b.expire(name) })
+		}
+
+		q.push(msg)
+		q.notify()
+	}
+
+	return nil
+}
+
+// route 找到消息应投递的队列, 无法路由的消息与 RabbitMQ 一样直接丢弃.
+func (b *MemoryBroker) route(exchange, key string) ([]*memQueue, error) {
+	if exchange == "" {
+		q, ok := b.queues[key]
+		if !ok {
+			return nil, nil
+		}
+
+		return []*memQueue{q}, nil
+	}
+
+	ex, ok := b.exchanges[exchange]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMemExchangeNotFound, exchange)
+	}
+
+	seen := make(map[string]bool, len(ex.bindings))
+	queues := make([]*memQueue, 0, len(ex.bindings))
+
+	for _, bd := range ex.bindings {
+		if seen[bd.queue] {
+			continue
+		}
+
+		var match bool
+
+		switch ex.kind {
+		case ExKindFanout:
+			match = true
+		case ExKindDirect:
+			match = bd.key == key
+		case ExKindTopic:
+			match = matchTopic(bd.key, key)
+		}
+
+		q, ok := b.queues[bd.queue]
+		if match && ok {
+			seen[bd.queue] = true
+			queues = append(queues, q)
+		}
+	}
+
+	return queues, nil
+}
+
+// expire 移除队首已过期的消息, 与 RabbitMQ 一样只检查队首.
+func (b *MemoryBroker) expire(queue string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return
+	}
+
+	b.expireLocked(q, time.Now())
+}
+
+func (b *MemoryBroker) expireLocked(q *memQueue, now time.Time) {
+	for len(q.msgs) > 0 {
+		head := q.msgs[0]
+		if head.expireAt.IsZero() || head.expireAt.After(now) {
+			return
+		}
+
+		q.msgs = q.msgs[1:]
+		b.deadLetterLocked(q, head)
+	}
+}
+
+// deadLetterLocked 将过期或被拒绝的消息投递到死信邮局, 未配置死信时丢弃.
+func (b *MemoryBroker) deadLetterLocked(q *memQueue, msg *memMessage) {
+	if !q.hasDLX {
+		return
+	}
+
+	key := msg.key
+	if q.dlKey != "" {
+		key = q.dlKey
+	}
+
+	pub := msg.pub
+	pub.Expiration = ""
+
+	_ = b.publishLocked(q.dlx, key, pub)
+}
+
+// pop 取出队首消息, 队列为空时返回 nil 与等待通知的通道.
+func (b *MemoryBroker) pop(queue string) (*memMessage, <-chan struct{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrMemQueueNotFound, queue)
+	}
+
+	b.expireLocked(q, time.Now())
+
+	if len(q.msgs) == 0 {
+		return nil, q.ready, nil
+	}
+
+	msg := q.msgs[0]
+	q.msgs = q.msgs[1:]
+
+	if len(q.msgs) > 0 {
+		q.notify()
+	}
+
+	return msg, q.ready, nil
+}
+
+func (b *MemoryBroker) requeue(queue string, msgs []*memMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return
+	}
+
+	for _, msg := range msgs {
+		msg.redelivered = true
+		q.push(msg)
+	}
+
+	q.notify()
+}
+
+func (b *MemoryBroker) reject(queue string, msgs []*memMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return
+	}
+
+	for _, msg := range msgs {
+		b.deadLetterLocked(q, msg)
+	}
+}
+
+func (b *MemoryBroker) addConsumer(queue string, delta int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if q, ok := b.queues[queue]; ok {
+		q.consumers += delta
+	}
+}
+
+// push 将消息加入队列, 优先级队列中排在优先级不低于它的消息之后.
+func (q *memQueue) push(msg *memMessage) {
+	if q.priority == 0 {
+		q.msgs = append(q.msgs, msg)
+		return
+	}
+
+	p := q.msgPriority(msg)
+	i := len(q.msgs)
+	for i > 0 && q.msgPriority(q.msgs[i-1]) < p {
+		i--
+	}
+
+	q.msgs = append(q.msgs, nil)
+	copy(q.msgs[i+1:], q.msgs[i:])
+	q.msgs[i] = msg
+}
+
+// msgPriority 返回消息的优先级, 超过 x-max-priority 时按最大优先级处理.
+func (q *memQueue) msgPriority(msg *memMessage) uint8 {
+	if msg.pub.Priority > q.priority {
+		return q.priority
+	}
+
+	return msg.pub.Priority
+}
+
+func (q *memQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// GetMsg 获取单条信息.
+func (b *MemoryBroker) GetMsg(queue string) ([]byte, error) {
+	msg, _, err := b.pop(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg == nil {
+		return nil, errors.New("ch get error")
+	}
+
+	body, err := decompressBody(msg.pub.ContentEncoding, msg.pub.Body, 0)
+	if err != nil || body != nil {
+		return body, err
+	}
+
+	return msg.pub.Body, nil
+}
+
+// Consume 消费信息.
+// 回调返回错误时消息保持未确认, 与 RabbitMQ 一样在消费者停止后才重新入队.
+func (b *MemoryBroker) Consume(queue string, fn ConsumeCallBackFunc) error {
+	return b.ConsumeDelivery(queue, func(_ context.Context, d amqp.Delivery) error {
+		return fn(d.Body)
+	})
+}
+
+// ConsumeDelivery 消费信息, 回调返回nil时ack, 返回错误时消息保持未确认,
+// 消费者停止(Close)时未确认的消息重新入队, 对应 RabbitMQ 通道关闭后重新投递.
+// 回调返回 Requeue 或 Discard 包装的错误时立即重新入队或拒绝.
+func (b *MemoryBroker) ConsumeDelivery(queue string, fn DeliveryCallBackFunc) error {
+	ch := newMemChannel(b, queue)
+	defer ch.close()
+
+	b.addConsumer(queue, 1)
+	defer b.addConsumer(queue, -1)
+
+	for {
+		msg, err := ch.next(b.cxt)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+
+			return err
+		}
+
+		if err = DecompressDelivery(&msg); err != nil {
+			_ = msg.Reject(false)
+			continue
+		}
+
+		if err = fn(extractTrace(b.cxt, msg), msg); err == nil {
+			_ = msg.Ack(false)
+		} else {
+			settleFailed(msg, err)
+		}
+	}
+}
+
+// SyncCallBackMsg 同 RabbitMQ.SyncCallBackMsg, 接收方需向 back_exchange/back_queue 回复消息.
+func (b *MemoryBroker) SyncCallBackMsg(toExchange, toKey, msg string, useDefExchange bool, timeout int) ([]byte, error) {
+	tmpExchange := ""
+	rand.Seed(time.Now().UnixNano())
+	num := rand.Intn(1000)
+	if !useDefExchange {
+		tmpExchange = fmt.Sprintf("prootocol.tmp.ex_%d_%d", time.Now().Unix(), num)
+	}
+	tmpQueue := fmt.Sprintf("prootocol.tmp.qu_%d_%d", time.Now().Unix(), num)
+
+	err := b.DeclareTmpQueue(tmpExchange, tmpQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(&CallbackPubMsg{
+		BackExchange: tmpExchange,
+		BackQueue:    tmpQueue,
+		Content:      msg,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.publish(toExchange, toKey, amqp.Publishing{ContentType: "text/json", Body: data})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(b.cxt, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	reply, err := newMemChannel(b, tmpQueue).next(ctx)
+	if err != nil {
+		return nil, errors.New("mq callback timeout")
+	}
+	_ = reply.Ack(false)
+
+	if err = DecompressDelivery(&reply); err != nil {
+		return nil, err
+	}
+
+	return reply.Body, nil
+}
+
+func (b *MemoryBroker) DeclareDirectEx(exchange string) error {
+	return b.declareExchange(exchange, ExKindDirect)
+}
+
+func (b *MemoryBroker) DeclareFanoutEx(exchange string) error {
+	return b.declareExchange(exchange, ExKindFanout)
+}
+
+func (b *MemoryBroker) DeclareTopicEx(exchange string) error {
+	return b.declareExchange(exchange, ExKindTopic)
+}
+
+func (b *MemoryBroker) declareExchange(exchange, kind string) error {
+	if exchange == "" {
+		return errors.New("exchange is null")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ex, ok := b.exchanges[exchange]; ok {
+		if ex.kind != kind {
+			return fmt.Errorf("exchange %s already declared as %s", exchange, ex.kind)
+		}
+
+		return nil
+	}
+
+	b.exchanges[exchange] = &memExchange{kind: kind}
+
+	return nil
+}
+
+// DeclareBindQueue 声明和绑定队列.
+func (b *MemoryBroker) DeclareBindQueue(queue, exchange, key string) error {
+	return b.DeclareBindQueueWithArgs(queue, exchange, key, nil)
+}
+
+// DeclareBindQueueWithArgs 声明和绑定队列, 支持 x-message-ttl、x-dead-letter-exchange、x-dead-letter-routing-key.
+func (b *MemoryBroker) DeclareBindQueueWithArgs(queue, exchange, key string, args amqp.Table) error {
+	if queue == "" {
+		return errors.New("queue or ch or exchange is null")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.queues[queue]; !ok {
+		q, err := newMemQueue(queue, args)
+		if err != nil {
+			return err
+		}
+
+		b.queues[queue] = q
+	}
+
+	if exchange == "" {
+		return nil
+	}
+
+	ex, ok := b.exchanges[exchange]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrMemExchangeNotFound, exchange)
+	}
+
+	for _, bd := range ex.bindings {
+		if bd.queue == queue && bd.key == key {
+			return nil
+		}
+	}
+
+	ex.bindings = append(ex.bindings, memBinding{queue: queue, key: key})
+
+	return nil
+}
+
+// DeclareTmpQueue 创建临时直联队列和邮局.
+func (b *MemoryBroker) DeclareTmpQueue(tmpExchange, tmpQueue string) error {
+	if tmpQueue == "" {
+		return errors.New("queue is null")
+	}
+
+	if tmpExchange != "" {
+		if err := b.declareExchange(tmpExchange, ExKindDirect); err != nil {
+			return err
+		}
+	}
+
+	return b.DeclareBindQueue(tmpQueue, tmpExchange, tmpQueue)
+}
+
+// InspectQueue 查看队列的消息数与消费者数.
+func (b *MemoryBroker) InspectQueue(queue string) (QueueInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return QueueInfo{}, fmt.Errorf("%w: %s", ErrMemQueueNotFound, queue)
+	}
+
+	b.expireLocked(q, time.Now())
+
+	return QueueInfo{Name: q.name, Messages: len(q.msgs), Consumers: q.consumers}, nil
+}
+
+// PurgeQueue 清空队列中待投递的消息.
+func (b *MemoryBroker) PurgeQueue(queue string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrMemQueueNotFound, queue)
+	}
+
+	n := len(q.msgs)
+	q.msgs = nil
+
+	return n, nil
+}
+
+// DeleteQueue 删除队列及其绑定.
+func (b *MemoryBroker) DeleteQueue(queue string, ifUnused, ifEmpty bool) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrMemQueueNotFound, queue)
+	}
+
+	if ifUnused && q.consumers > 0 {
+		return 0, fmt.Errorf("queue %s in use", queue)
+	}
+
+	if ifEmpty && len(q.msgs) > 0 {
+		return 0, fmt.Errorf("queue %s not empty", queue)
+	}
+
+	for _, ex := range b.exchanges {
+		bindings := ex.bindings[:0]
+		for _, bd := range ex.bindings {
+			if bd.queue != queue {
+				bindings = append(bindings, bd)
+			}
+		}
+		ex.bindings = bindings
+	}
+
+	delete(b.queues, queue)
+	q.notify()
+
+	return len(q.msgs), nil
+}
+
+// MoveMessages 将 from 队列的消息移动到 to 队列, limit<=0 时移动全部消息.
+// from 与 to 相同时返回 ErrMoveSameQueue.
+func (b *MemoryBroker) MoveMessages(from, to string, limit int) (int, error) {
+	if from == to {
+		return 0, ErrMoveSameQueue
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	src, ok := b.queues[from]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrMemQueueNotFound, from)
+	}
+
+	if _, ok = b.queues[to]; !ok {
+		return 0, fmt.Errorf("%w: %s", ErrMemQueueNotFound, to)
+	}
+
+	b.expireLocked(src, time.Now())
+	limit = moveLimit(limit, len(src.msgs))
+
+	moved := 0
+	for moved < limit {
+		msg := src.msgs[0]
+		src.msgs = src.msgs[1:]
+
+		if err := b.publishLocked("", to, msg.pub); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+
+	return moved, nil
+}
+
+// PeekMessages 查看队首的 count 条消息而不消费.
+func (b *MemoryBroker) PeekMessages(queue string, count int) ([]PeekMessage, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMemQueueNotFound, queue)
+	}
+
+	b.expireLocked(q, time.Now())
+
+	if count > len(q.msgs) {
+		count = len(q.msgs)
+	}
+
+	msgs := make([]PeekMessage, 0, count)
+	for _, msg := range q.msgs[:count] {
+		msgs = append(msgs, PeekMessage{
+			Exchange:        msg.exchange,
+			RoutingKey:      msg.key,
+			MessageId:       msg.pub.MessageId,
+			ContentType:     msg.pub.ContentType,
+			ContentEncoding: msg.pub.ContentEncoding,
+			Headers:         msg.pub.Headers,
+			Timestamp:       msg.pub.Timestamp,
+			Redelivered:     msg.redelivered,
+			Body:            peekBody(msg.pub.ContentEncoding, msg.pub.Body),
+		})
+	}
+
+	return msgs, nil
+}
+
+func (b *MemoryBroker) Close() {
+	b.cancel()
+}
+
+func newMemQueue(name string, args amqp.Table) (*memQueue, error) {
+	q := &memQueue{
+		name:  name,
+		ready: make(chan struct{}, 1),
+	}
+
+	if v, ok := args["x-message-ttl"]; ok {
+		ms, err := tableInt(v)
+		if err != nil {
+			return nil, fmt.Errorf("x-message-ttl: %w", err)
+		}
+
+		q.ttl = time.Duration(ms) * time.Millisecond
+	}
+
+	if v, ok := args["x-dead-letter-exchange"].(string); ok {
+		q.dlx = v
+		q.hasDLX = true
+	}
+
+	if v, ok := args["x-max-priority"]; ok {
+		n, err := tableInt(v)
+		if err != nil {
+			return nil, fmt.Errorf("x-max-priority: %w", err)
+		}
+
+		if n < 0 || n > maxPriority {
+			return nil, fmt.Errorf("x-max-priority: %d out of range [0, %d]", n, maxPriority)
+		}
+
+		q.priority = uint8(n)
+	}
+
+	if v, ok := args["x-dead-letter-routing-key"].(string); ok {
+		q.dlKey = v
+	}
+
+	return q, nil
+}
+
+// memChannel 模拟一个消费通道, 记录未确认的消息, 实现 amqp.Acknowledger.
+type memChannel struct {
+	b       *MemoryBroker
+	queue   string
+	mu      sync.Mutex
+	tag     uint64
+	unacked map[uint64]*memMessage
+}
+
+func newMemChannel(b *MemoryBroker, queue string) *memChannel {
+	return &memChannel{
+		b:       b,
+		queue:   queue,
+		unacked: make(map[uint64]*memMessage),
+	}
+}
+
+// close 将未确认的消息按投递顺序重新入队.
+func (c *memChannel) close() {
+	c.mu.Lock()
+	tags := make([]uint64, 0, len(c.unacked))
+	for tag := range c.unacked {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	msgs := make([]*memMessage, 0, len(tags))
+	for _, tag := range tags {
+		msgs = append(msgs, c.unacked[tag])
+		delete(c.unacked, tag)
+	}
+	c.mu.Unlock()
+
+	if len(msgs) > 0 {
+		c.b.requeue(c.queue, msgs)
+	}
+}
+
+// next 阻塞直到取得消息或 ctx 结束.
+func (c *memChannel) next(ctx context.Context) (amqp.Delivery, error) {
+	for {
+		msg, ready, err := c.b.pop(c.queue)
+		if err != nil {
+			return amqp.Delivery{}, err
+		}
+
+		if msg != nil {
+			return c.deliver(msg), nil
+		}
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return amqp.Delivery{}, ctx.Err()
+		}
+	}
+}
+
+func (c *memChannel) deliver(msg *memMessage) amqp.Delivery {
+	c.mu.Lock()
+	c.tag++
+	tag := c.tag
+	c.unacked[tag] = msg
+	c.mu.Unlock()
+
+	pub := msg.pub
+
+	return amqp.Delivery{
+		Acknowledger:    c,
+		Headers:         pub.Headers,
+		ContentType:     pub.ContentType,
+		ContentEncoding: pub.ContentEncoding,
+		DeliveryMode:    pub.DeliveryMode,
+		Priority:        pub.Priority,
+		CorrelationId:   pub.CorrelationId,
+		ReplyTo:         pub.ReplyTo,
+		Expiration:      pub.Expiration,
+		MessageId:       pub.MessageId,
+		Timestamp:       pub.Timestamp,
+		Type:            pub.Type,
+		UserId:          pub.UserId,
+		AppId:           pub.AppId,
+		DeliveryTag:     tag,
+		Redelivered:     msg.redelivered,
+		Exchange:        msg.exchange,
+		RoutingKey:      msg.key,
+		Body:            pub.Body,
+	}
+}
+
+// settle 移除已确认的消息, multiple 为 true 时包含所有小于等于 tag 的消息.
+func (c *memChannel) settle(tag uint64, multiple bool) ([]*memMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !multiple {
+		msg, ok := c.unacked[tag]
+		if !ok {
+			return nil, ErrMemUnknownTag
+		}
+
+		delete(c.unacked, tag)
+
+		return []*memMessage{msg}, nil
+	}
+
+	msgs := make([]*memMessage, 0, len(c.unacked))
+	for t := uint64(1); t <= tag; t++ {
+		if msg, ok := c.unacked[t]; ok {
+			msgs = append(msgs, msg)
+			delete(c.unacked, t)
+		}
+	}
+
+	if len(msgs) == 0 {
+		return nil, ErrMemUnknownTag
+	}
+
+	return msgs, nil
+}
+
+func (c *memChannel) Ack(tag uint64, multiple bool) error {
+	_, err := c.settle(tag, multiple)
+
+	return err
+}
+
+func (c *memChannel) Nack(tag uint64, multiple bool, requeue bool) error {
+	msgs, err := c.settle(tag, multiple)
+	if err != nil {
+		return err
+	}
+
+	if requeue {
+		c.b.requeue(c.queue, msgs)
+	} else {
+		c.b.reject(c.queue, msgs)
+	}
+
+	return nil
+}
+
+func (c *memChannel) Reject(tag uint64, requeue bool) error {
+	return c.Nack(tag, false, requeue)
+}
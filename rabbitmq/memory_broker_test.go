@@ -0,0 +1,241 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestMemoryBroker_Route(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareDirectEx("test.direct")
+	_ = mq.DeclareFanoutEx("test.fanout")
+	_ = mq.DeclareTopicEx("test.topic")
+	_ = mq.DeclareBindQueue("direct.a", "test.direct", "a")
+	_ = mq.DeclareBindQueue("fanout.a", "test.fanout", "")
+	_ = mq.DeclareBindQueue("fanout.b", "test.fanout", "")
+	_ = mq.DeclareBindQueue("topic.report", "test.topic", "device.*.report")
+	_ = mq.DeclareBindQueue("topic.all", "test.topic", "device.#")
+
+	tests := []struct {
+		name     string
+		exchange string
+		key      string
+		queues   []string
+		empty    []string
+	}{
+		{"direct匹配", "test.direct", "a", []string{"direct.a"}, nil},
+		{"direct不匹配", "test.direct", "b", nil, []string{"direct.a"}},
+		{"fanout", "test.fanout", "any", []string{"fanout.a", "fanout.b"}, nil},
+		{"topic星号", "test.topic", "device.101.report", []string{"topic.report", "topic.all"}, nil},
+		{"topic井号", "test.topic", "device.101.alarm.high", []string{"topic.all"}, []string{"topic.report"}},
+		{"默认邮局", "", "direct.a", []string{"direct.a"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := mq.Publish(tt.exchange, tt.key, tt.name); err != nil {
+				t.Fatal("publish err:", err)
+			}
+
+			for _, q := range tt.queues {
+				msg, err := mq.GetMsg(q)
+				if err != nil {
+					t.Fatalf("queue %s get msg err: %v", q, err)
+				}
+
+				var got string
+				_ = json.Unmarshal(msg, &got)
+				if got != tt.name {
+					t.Errorf("queue %s got %q, want %q", q, got, tt.name)
+				}
+			}
+
+			for _, q := range tt.empty {
+				if _, err := mq.GetMsg(q); err == nil {
+					t.Errorf("queue %s should be empty", q)
+				}
+			}
+		})
+	}
+
+	if err := mq.Publish("not.exist", "", "x"); !errors.Is(err, ErrMemExchangeNotFound) {
+		t.Error("publish to unknown exchange should fail, got:", err)
+	}
+}
+
+func TestMemoryBroker_ConsumeRequeue(t *testing.T) {
+	mq := NewMemoryBroker()
+	_ = mq.DeclareBindQueue("test.requeue", "", "")
+	_ = mq.Publish("", "test.requeue", "retry me")
+
+	var calls int32
+	stopped := make(chan struct{})
+	go func() {
+		_ = mq.Consume("test.requeue", func(data []byte) error {
+			atomic.AddInt32(&calls, 1)
+
+			return errors.New("always fail")
+		})
+		close(stopped)
+	}()
+
+	// 回调失败的消息保持未确认, 不会立即重新投递
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("callback called %d times, want 1", n)
+	}
+	if info, _ := mq.InspectQueue("test.requeue"); info.Messages != 0 {
+		t.Errorf("unacked message should not be ready, got %d", info.Messages)
+	}
+
+	mq.Close()
+	<-stopped
+
+	msgs, _ := mq.PeekMessages("test.requeue", 1)
+	if len(msgs) != 1 || !msgs[0].Redelivered {
+		t.Errorf("unacked message should be requeued as redelivered after consumer stops, got %+v", msgs)
+	}
+}
+
+func TestMemoryBroker_ConsumeSettle(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareDirectEx("test.dlx")
+	_ = mq.DeclareBindQueue("test.dead", "test.dlx", "dead")
+	_ = mq.DeclareBindQueueWithArgs("test.settle", "", "", amqp.Table{
+		"x-dead-letter-exchange":    "test.dlx",
+		"x-dead-letter-routing-key": "dead",
+	})
+	_ = mq.Publish("", "test.settle", "requeue")
+	_ = mq.Publish("", "test.settle", "discard")
+
+	var requeued int32
+	done := make(chan struct{})
+	go func() {
+		_ = mq.ConsumeDelivery("test.settle", func(_ context.Context, d amqp.Delivery) error {
+			var body string
+			_ = json.Unmarshal(d.Body, &body)
+
+			if body == "discard" {
+				return Discard(errors.New("malformed"))
+			}
+			if atomic.AddInt32(&requeued, 1) == 1 {
+				return Requeue(errors.New("busy"))
+			}
+			close(done)
+
+			return nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("requeued message was not redelivered")
+	}
+
+	if _, err := mq.GetMsg("test.dead"); err != nil {
+		t.Error("discarded message should be dead-lettered:", err)
+	}
+}
+
+func TestMemoryBroker_MaxPriority(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	if err := mq.DeclareBindQueueWithArgs("test.priority", "", "", amqp.Table{"x-max-priority": int32(256)}); err == nil {
+		t.Error("x-max-priority over 255 should fail")
+	}
+	if err := mq.DeclareBindQueueWithArgs("test.priority", "", "", amqp.Table{"x-max-priority": int32(10)}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemoryBroker_TTLDeadLetter(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareDirectEx("test.dlx")
+	_ = mq.DeclareBindQueue("test.target", "test.dlx", "target")
+	err := mq.DeclareBindQueueWithArgs("test.delay", "", "", amqp.Table{
+		"x-message-ttl":             int32(50),
+		"x-dead-letter-exchange":    "test.dlx",
+		"x-dead-letter-routing-key": "target",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = mq.Publish("", "test.delay", "delayed")
+	if _, err = mq.GetMsg("test.target"); err == nil {
+		t.Fatal("message should not arrive before ttl")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err = mq.GetMsg("test.delay"); err == nil {
+		t.Error("expired message should leave the ttl queue")
+	}
+	if _, err = mq.GetMsg("test.target"); err != nil {
+		t.Error("expired message should be dead-lettered:", err)
+	}
+}
+
+func TestMemoryBroker_SyncCallBackMsg(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareDirectEx("test.rpc")
+	_ = mq.DeclareBindQueue("test.rpc.server", "test.rpc", "call")
+
+	go func() {
+		_ = mq.Consume("test.rpc.server", func(data []byte) error {
+			var cpMsg CallbackPubMsg
+			if err := json.Unmarshal(data, &cpMsg); err != nil {
+				return err
+			}
+
+			return mq.Publish(cpMsg.BackExchange, cpMsg.BackQueue, "pong:"+cpMsg.Content)
+		})
+	}()
+
+	msg, err := mq.SyncCallBackMsg("test.rpc", "call", "ping", false, 1)
+	if err != nil {
+		t.Fatal("sync callback msg err:", err)
+	}
+
+	var got string
+	_ = json.Unmarshal(msg, &got)
+	if got != "pong:ping" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"device.*.report", "device.1.report", true},
+		{"device.*.report", "device.report", false},
+		{"device.#", "device", true},
+		{"device.#", "device.1.report", true},
+		{"#.report", "device.1.report", true},
+		{"#", "", true},
+		{"device.*", "device.1.report", false},
+		{"device.1", "device.1", true},
+	}
+	for _, tt := range tests {
+		if got := matchTopic(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("matchTopic(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/qumogu/go-tools/mongodb"
+	"github.com/qumogu/go-tools/rabbitmq"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ rabbitmq.DedupStore = (*DedupStore)(nil)
+
+const (
+	DefaultDedupCollection = "mq_dedup"
+	DefaultDedupTTL        = 7 * 24 * time.Hour
+	DefaultDedupLease      = 5 * time.Minute
+
+	dedupStatusProcessing = "processing"
+	dedupStatusDone       = "done"
+)
+
+type dedupRecord struct {
+	Key        string    `bson:"_id"`
+	Status     string    `bson:"status"`
+	Owner      string    `bson:"owner"`       // 持有处理权的消费者, 每次获得处理权时生成
+	LeaseUntil time.Time `bson:"lease_until"` // 处理中的租约到期时间, 到期后认为处理者已崩溃
+	ExpireAt   time.Time `bson:"expire_at"`   // TTL 索引字段, 到期后记录被 mongo 删除
+}
+
+// DedupConfig 去重存储配置, 零值使用默认值.
+type DedupConfig struct {
+	Database   string        // 数据库名称
+	Collection string        // 集合名称, 默认 mq_dedup
+	TTL        time.Duration // 去重记录保留时长, 应大于消息可能被重复投递的时间窗口, 默认7天
+	Lease      time.Duration // 单条消息最长处理时间, 超时后其他消费者可重新处理, 默认5分钟
+}
+
+// DedupStore 基于 mongo 的消息去重记录, 实现 rabbitmq.DedupStore.
+// 以去重key作为 _id 插入记录, 依赖唯一索引保证并发消费者中只有一个获得处理权.
+type DedupStore struct {
+	mgr  *mongodb.MongoManager
+	conf DedupConfig
+}
+
+func NewDedupStore(mgr *mongodb.MongoManager, conf DedupConfig) *DedupStore {
+	if conf.Collection == "" {
+		conf.Collection = DefaultDedupCollection
+	}
+
+	if conf.TTL <= 0 {
+		conf.TTL = DefaultDedupTTL
+	}
+
+	if conf.Lease <= 0 {
+		conf.Lease = DefaultDedupLease
+	}
+
+	return &DedupStore{
+		mgr:  mgr,
+		conf: conf,
+	}
+}
+
+func (s *DedupStore) getCollection() (*mongo.Collection, error) {
+	db, err := s.mgr.GetDB(s.conf.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.Collection(s.conf.Collection), nil
+}
+
+// EnsureIndexes 创建 expire_at 的 TTL 索引.
+func (s *DedupStore) EnsureIndexes(ctx context.Context) error {
+	coll, err := s.getCollection()
+	if err != nil {
+		return err
+	}
+
+	_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expire_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	return err
+}
+
+func (s *DedupStore) Claim(ctx context.Context, key string) (rabbitmq.DedupState, string, error) {
+	coll, err := s.getCollection()
+	if err != nil {
+		return rabbitmq.DedupNew, "", err
+	}
+
+	ctx, cancel := mongodb.NewContextWithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	owner := primitive.NewObjectID().Hex()
+
+	_, err = coll.InsertOne(ctx, dedupRecord{
+		Key:        key,
+		Status:     dedupStatusProcessing,
+		Owner:      owner,
+		LeaseUntil: now.Add(s.conf.Lease),
+		ExpireAt:   now.Add(s.conf.TTL),
+	})
+	if err == nil {
+		return rabbitmq.DedupNew, owner, nil
+	}
+
+	if !mongo.IsDuplicateKeyError(err) {
+		return rabbitmq.DedupNew, "", err
+	}
+
+	// 记录已存在, 若上一个处理者租约已过期则接管处理权
+	filter := bson.M{
+		"_id":         key,
+		"status":      dedupStatusProcessing,
+		"lease_until": bson.M{"$lte": now},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"owner":       owner,
+			"lease_until": now.Add(s.conf.Lease),
+		},
+	}
+
+	result, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return rabbitmq.DedupNew, "", err
+	}
+
+	if result.ModifiedCount > 0 {
+		return rabbitmq.DedupNew, owner, nil
+	}
+
+	record := dedupRecord{}
+
+	err = coll.FindOne(ctx, bson.M{"_id": key}).Decode(&record)
+	if err != nil {
+		// 记录在两次查询之间被 TTL 删除或被释放, 由重新投递的消息再次尝试
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return rabbitmq.DedupProcessing, "", nil
+		}
+
+		return rabbitmq.DedupNew, "", err
+	}
+
+	if record.Status == dedupStatusDone {
+		return rabbitmq.DedupDone, "", nil
+	}
+
+	return rabbitmq.DedupProcessing, "", nil
+}
+
+// ownerFilter 只匹配仍由 token 持有处理权的记录.
+func ownerFilter(key, token string) bson.M {
+	return bson.M{
+		"_id":    key,
+		"status": dedupStatusProcessing,
+		"owner":  token,
+	}
+}
+
+func (s *DedupStore) Complete(ctx context.Context, key, token string) error {
+	coll, err := s.getCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := mongodb.NewContextWithTimeout(ctx)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":    dedupStatusDone,
+			"expire_at": time.Now().Add(s.conf.TTL),
+		},
+	}
+	result, err := coll.UpdateOne(ctx, ownerFilter(key, token), update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return rabbitmq.ErrDedupNotOwner
+	}
+
+	return nil
+}
+
+func (s *DedupStore) Release(ctx context.Context, key, token string) error {
+	coll, err := s.getCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := mongodb.NewContextWithTimeout(ctx)
+	defer cancel()
+
+	result, err := coll.DeleteOne(ctx, ownerFilter(key, token))
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return rabbitmq.ErrDedupNotOwner
+	}
+
+	return nil
+}
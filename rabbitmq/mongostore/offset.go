@@ -0,0 +1,107 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/qumogu/go-tools/mongodb"
+	"github.com/qumogu/go-tools/rabbitmq"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ rabbitmq.OffsetStore = (*OffsetStore)(nil)
+
+const DefaultOffsetCollection = "mq_stream_offset"
+
+type offsetRecord struct {
+	ID          string    `bson:"_id"` // consumer/queue
+	Consumer    string    `bson:"consumer"`
+	Queue       string    `bson:"queue"`
+	Offset      int64     `bson:"offset"`
+	UpdatedTime time.Time `bson:"updated_time"`
+}
+
+// OffsetConfig 偏移量存储配置, 零值使用默认值.
+type OffsetConfig struct {
+	Database   string // 数据库名称
+	Collection string // 集合名称, 默认 mq_stream_offset
+}
+
+// OffsetStore 基于 mongo 的流队列消费偏移量记录, 实现 rabbitmq.OffsetStore.
+type OffsetStore struct {
+	mgr  *mongodb.MongoManager
+	conf OffsetConfig
+}
+
+func NewOffsetStore(mgr *mongodb.MongoManager, conf OffsetConfig) *OffsetStore {
+	if conf.Collection == "" {
+		conf.Collection = DefaultOffsetCollection
+	}
+
+	return &OffsetStore{
+		mgr:  mgr,
+		conf: conf,
+	}
+}
+
+func (s *OffsetStore) getCollection() (*mongo.Collection, error) {
+	db, err := s.mgr.GetDB(s.conf.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.Collection(s.conf.Collection), nil
+}
+
+func offsetID(consumer, queue string) string {
+	return consumer + "/" + queue
+}
+
+func (s *OffsetStore) LoadOffset(ctx context.Context, consumer, queue string) (int64, bool, error) {
+	coll, err := s.getCollection()
+	if err != nil {
+		return 0, false, err
+	}
+
+	ctx, cancel := mongodb.NewContextWithTimeout(ctx)
+	defer cancel()
+
+	record := offsetRecord{}
+
+	err = coll.FindOne(ctx, bson.M{"_id": offsetID(consumer, queue)}).Decode(&record)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, false, nil
+		}
+
+		return 0, false, err
+	}
+
+	return record.Offset, true, nil
+}
+
+// SaveOffset 保存偏移量, 只会向前推进, 避免并发或延迟的写入覆盖更新的偏移量.
+func (s *OffsetStore) SaveOffset(ctx context.Context, consumer, queue string, offset int64) error {
+	coll, err := s.getCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := mongodb.NewContextWithTimeout(ctx)
+	defer cancel()
+
+	update := bson.M{
+		"$max": bson.M{"offset": offset},
+		"$set": bson.M{
+			"consumer":     consumer,
+			"queue":        queue,
+			"updated_time": time.Now(),
+		},
+	}
+	_, err = coll.UpdateByID(ctx, offsetID(consumer, queue), update, options.Update().SetUpsert(true))
+
+	return err
+}
@@ -0,0 +1,139 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// newJSONPublishing 以 json 编码消息, 默认持久化
+func newJSONPublishing(msg interface{}) (amqp.Publishing, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+	return amqp.Publishing{
+		ContentType:  "text/json",
+		Body:         body,
+		DeliveryMode: amqp.Persistent,
+	}, nil
+}
+
+// QueueOptions 常用的队列参数, 零值的字段不设置.
+type QueueOptions struct {
+	MaxPriority          uint8         // x-max-priority 优先级队列的最大优先级, 建议不超过10
+	MessageTTL           time.Duration // x-message-ttl 队列中消息的过期时间
+	Expires              time.Duration // x-expires 队列无消费者且未被使用多久后删除
+	MaxLength            int           // x-max-length 最大消息数
+	MaxLengthBytes       int           // x-max-length-bytes 消息体总大小上限
+	Overflow             string        // x-overflow 超过上限时的处理: drop-head(默认)、reject-publish、reject-publish-dlx
+	DeadLetterExchange   string        // x-dead-letter-exchange 死信邮局
+	DeadLetterRoutingKey string        // x-dead-letter-routing-key 死信路由key, 为空时使用原路由key
+	Args                 amqp.Table    // 其他队列参数, 与上述字段重复时以 Args 为准
+}
+
+// Table 转换为声明队列使用的参数.
+func (o QueueOptions) Table() amqp.Table {
+	args := amqp.Table{}
+	if o.MaxPriority > 0 {
+		args["x-max-priority"] = o.MaxPriority
+	}
+	if o.MessageTTL > 0 {
+		args["x-message-ttl"] = o.MessageTTL.Milliseconds()
+	}
+	if o.Expires > 0 {
+		args["x-expires"] = o.Expires.Milliseconds()
+	}
+	if o.MaxLength > 0 {
+		args["x-max-length"] = o.MaxLength
+	}
+	if o.MaxLengthBytes > 0 {
+		args["x-max-length-bytes"] = o.MaxLengthBytes
+	}
+	if o.Overflow != "" {
+		args["x-overflow"] = o.Overflow
+	}
+	if o.DeadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = o.DeadLetterExchange
+	}
+	if o.DeadLetterRoutingKey != "" {
+		args["x-dead-letter-routing-key"] = o.DeadLetterRoutingKey
+	}
+	for k, v := range o.Args {
+		args[k] = v
+	}
+	return args
+}
+
+// PublishOptions 发布消息的属性, 零值的字段不设置.
+type PublishOptions struct {
+	Priority      uint8         // 消息优先级, 只在设置了 MaxPriority 的队列中生效
+	Expiration    time.Duration // 消息过期时间, 不足1ms时按1ms处理
+	MessageId     string
+	CorrelationId string
+	Headers       amqp.Table
+	Transient     bool // 不持久化消息, 适用于可丢失的高频消息
+}
+
+func (o PublishOptions) apply(pub *amqp.Publishing) {
+	pub.Priority = o.Priority
+	if o.Expiration > 0 {
+		ms := o.Expiration.Milliseconds()
+		if ms < 1 {
+			ms = 1
+		}
+		pub.Expiration = strconv.FormatInt(ms, 10)
+	}
+	if o.MessageId != "" {
+		pub.MessageId = o.MessageId
+	}
+	if o.CorrelationId != "" {
+		pub.CorrelationId = o.CorrelationId
+	}
+	if o.Headers != nil {
+		pub.Headers = o.Headers
+	}
+	if o.Transient {
+		pub.DeliveryMode = amqp.Transient
+	}
+}
+
+// DeclareQueue 按 opts 声明队列并绑定到 exchange, exchange 为空时只声明队列
+func (r *RabbitMQ) DeclareQueue(queue, exchange, key string, opts QueueOptions) error {
+	return r.DeclareBindQueueWithArgs(queue, exchange, key, opts.Table())
+}
+
+// PublishWithOptions 按 opts 设置优先级、过期时间等属性后发布消息
+func (r *RabbitMQ) PublishWithOptions(ctx context.Context, exchange, key string, msg interface{}, opts PublishOptions) error {
+	pub, err := newJSONPublishing(msg)
+	if err != nil {
+		return err
+	}
+	opts.apply(&pub)
+	return r.publish(ctx, exchange, key, pub)
+}
+
+// DeclareQueue 按 opts 声明队列并绑定到 exchange.
+func (b *MemoryBroker) DeclareQueue(queue, exchange, key string, opts QueueOptions) error {
+	return b.DeclareBindQueueWithArgs(queue, exchange, key, opts.Table())
+}
+
+// PublishWithOptions 按 opts 设置属性后发布消息.
+func (b *MemoryBroker) PublishWithOptions(ctx context.Context, exchange, key string, msg interface{}, opts PublishOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	pub, err := newJSONPublishing(msg)
+	if err != nil {
+		return err
+	}
+
+	opts.apply(&pub)
+	injectTrace(ctx, &pub)
+
+	return b.publish(exchange, key, pub)
+}
@@ -0,0 +1,78 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestQueueOptions_Table(t *testing.T) {
+	args := QueueOptions{
+		MaxPriority:        10,
+		MessageTTL:         time.Minute,
+		DeadLetterExchange: "test.dlx",
+		Args:               amqp.Table{"x-queue-mode": "lazy", "x-max-priority": uint8(5)},
+	}.Table()
+
+	want := amqp.Table{
+		"x-max-priority":         uint8(5),
+		"x-message-ttl":          int64(60000),
+		"x-dead-letter-exchange": "test.dlx",
+		"x-queue-mode":           "lazy",
+	}
+	if len(args) != len(want) {
+		t.Fatalf("args = %v, want %v", args, want)
+	}
+	for k, v := range want {
+		if args[k] != v {
+			t.Errorf("%s = %v, want %v", k, args[k], v)
+		}
+	}
+	if err := args.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPublishOptions_Apply(t *testing.T) {
+	pub := amqp.Publishing{DeliveryMode: amqp.Persistent}
+	PublishOptions{Priority: 3, Expiration: 500 * time.Microsecond, Transient: true}.apply(&pub)
+	if pub.Priority != 3 || pub.Expiration != "1" || pub.DeliveryMode != amqp.Transient {
+		t.Errorf("unexpected publishing: %+v", pub)
+	}
+}
+
+func TestMemoryBroker_PriorityQueue(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	ctx := context.Background()
+	_ = mq.DeclareDirectEx("test.device")
+	_ = mq.DeclareQueue("test.device.q", "test.device", "data", QueueOptions{MaxPriority: 5})
+
+	_ = mq.Publish("test.device", "data", "telemetry-1")
+	_ = mq.PublishWithOptions(ctx, "test.device", "data", "alarm-1", PublishOptions{Priority: 5})
+	_ = mq.Publish("test.device", "data", "telemetry-2")
+	// 超过最大优先级按最大优先级处理
+	_ = mq.PublishWithOptions(ctx, "test.device", "data", "alarm-2", PublishOptions{Priority: 9})
+	_ = mq.PublishWithOptions(ctx, "test.device", "data", "expired", PublishOptions{Expiration: time.Millisecond})
+
+	time.Sleep(10 * time.Millisecond)
+	want := []string{"alarm-1", "alarm-2", "telemetry-1", "telemetry-2"}
+	for _, w := range want {
+		body, err := mq.GetMsg("test.device.q")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		_ = json.Unmarshal(body, &got)
+		if got != w {
+			t.Fatalf("got %s, want %s", got, w)
+		}
+	}
+	if _, err := mq.GetMsg("test.device.q"); err == nil {
+		t.Error("expired message should be dropped")
+	}
+}
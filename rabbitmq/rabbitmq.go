@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -14,6 +15,15 @@ import (
 const (
 	ReconnectTry = 3
 )
+
+var (
+	ErrPublishNacked     = errors.New("rabbitmq publish nacked by broker")
+	ErrPublishUnroutable = errors.New("rabbitmq publish returned as unroutable")
+)
+
+// returnBufferSize 被退回消息的缓冲, 未及时读取时会阻塞连接的读取
+const returnBufferSize = 16
+
 const (
 	ExKindFanout = "fanout"
 	ExKindTopic  = "topic"
@@ -24,6 +34,42 @@ type DataReporter interface {
 }
 type ConsumeCallBackFunc func(data []byte) error
 
+// DeliveryCallBackFunc 消费回调, 可以读取消息的属性(MessageId、Headers、RoutingKey等)
+// 返回错误时消息保持未确认直到通道关闭, 需要立即处理时返回 Requeue 或 Discard 包装的错误
+type DeliveryCallBackFunc func(ctx context.Context, d amqp.Delivery) error
+
+// settleError 指定回调失败的消息的处理方式
+type settleError struct {
+	err     error
+	requeue bool
+}
+
+func (e *settleError) Error() string {
+	return e.err.Error()
+}
+
+func (e *settleError) Unwrap() error {
+	return e.err
+}
+
+// Requeue 包装回调的错误, 消息 nack 后重新入队, 用于暂时无法处理的消息
+func Requeue(err error) error {
+	return &settleError{err: err, requeue: true}
+}
+
+// Discard 包装回调的错误, 消息被拒绝且不重新入队, 配置了死信时进入死信队列, 用于无法处理的消息
+func Discard(err error) error {
+	return &settleError{err: err}
+}
+
+// settleFailed 按 Requeue 或 Discard 处理回调失败的消息, 其他错误保持未确认
+func settleFailed(d amqp.Delivery, err error) {
+	var se *settleError
+	if errors.As(err, &se) {
+		_ = d.Nack(false, se.requeue)
+	}
+}
+
 func SimpleCallback(data []byte) error {
 	fmt.Println(string(data))
 	time.Sleep(1 * time.Second)
@@ -47,50 +93,182 @@ type RabbitMQ struct {
 	defChan  *amqp.Channel
 	cxt      context.Context
 	cancel   context.CancelFunc
-	uri      string
+	cnf      *RabbitMQConfig
 	prefetch int
+	delay    *delayQueues
+
+	flow      *flowControl
+	pubBuffer chan bufferedPub // BlockedModeBuffer 时连接被阻塞期间的待发送消息
+
+	confirmLock sync.Mutex
+	confirmChan *amqp.Channel      // 确认模式的通道, 首次使用时创建
+	returns     <-chan amqp.Return // 确认模式通道上被退回的 mandatory 消息
 }
 
-func NewRabbitMQ(cnf *RabbitMQConfig) *RabbitMQ {
-	uri := cnf.GetUri()
-	conn, err := amqp.Dial(uri)
-	if err != nil {
-		panic("连接rabbitmq失败,err:" + err.Error())
-	}
-	ch, err := conn.Channel()
-	if err != nil {
-		panic("获取channel失败,err:" + err.Error())
-	}
-	err = ch.Qos(cnf.PrefetchCount, 0, false)
-	if err != nil {
-		panic("设置channel的Qos失败,err:" + err.Error())
-	}
+// NewRabbitMQ 连接rabbitmq, 配置了多个节点时依次尝试, 全部失败时返回错误
+func NewRabbitMQ(cnf *RabbitMQConfig) (*RabbitMQ, error) {
 	cxt, cancel := context.WithCancel(context.Background())
-	mq := RabbitMQ{
-		conn:     conn,
-		defChan:  ch,
+	mq := &RabbitMQ{
 		cxt:      cxt,
 		cancel:   cancel,
-		uri:      uri,
+		cnf:      cnf,
 		prefetch: cnf.PrefetchCount,
+		delay:    newDelayQueues(cnf.DelayBuckets),
+		flow:     newFlowControl(),
+	}
+	conn, ch, err := mq.connect()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	mq.conn = conn
+	mq.defChan = ch
+	mq.watchFlow(conn, ch)
+
+	if cnf.BlockedMode == BlockedModeBuffer {
+		size := cnf.BlockedBufferSize
+		if size <= 0 {
+			size = defaultBlockedBufferSize
+		}
+		mq.pubBuffer = make(chan bufferedPub, size)
+		go mq.drainBuffer()
 	}
 
-	return &mq
+	return mq, nil
+}
+
+// connect 按 HostSelect 的顺序尝试连接集群节点, 返回第一个可用的连接
+func (r *RabbitMQ) connect() (*amqp.Connection, *amqp.Channel, error) {
+	conf, err := r.cnf.getAmqpConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	var lastErr error
+	for _, uri := range r.cnf.GetUris() {
+		dialConf := conf
+		if conf.TLSClientConfig != nil {
+			// DialConfig 会修改 ServerName, 每个节点使用独立的副本
+			dialConf.TLSClientConfig = conf.TLSClientConfig.Clone()
+		}
+		conn, err := amqp.DialConfig(uri, dialConf)
+		if err != nil {
+			lastErr = fmt.Errorf("连接rabbitmq失败,err:%w", err)
+			continue
+		}
+		ch, err := conn.Channel()
+		if err != nil {
+			_ = conn.Close()
+			lastErr = fmt.Errorf("获取channel失败,err:%w", err)
+			continue
+		}
+		err = ch.Qos(r.prefetch, 0, false)
+		if err != nil {
+			_ = conn.Close()
+			lastErr = fmt.Errorf("设置channel的Qos失败,err:%w", err)
+			continue
+		}
+		return conn, ch, nil
+	}
+	return nil, nil, lastErr
 }
 
-// Publish 发布消息
+// Publish 发布消息, 超时时间为 PublishTimeout
 func (r *RabbitMQ) Publish(exchange, key string, msg interface{}) error {
-	body, err := json.Marshal(msg)
+	ctx, cancel := context.WithTimeout(r.cxt, r.publishTimeout())
+	defer cancel()
+	return r.PublishWithContext(ctx, exchange, key, msg)
+}
+
+// PublishConfirm 以确认模式发布消息, broker 确认后返回, 被拒绝时返回 ErrPublishNacked
+// 无法路由的消息也会被确认, 需要保证投递到队列时使用 PublishMandatory
+func (r *RabbitMQ) PublishConfirm(ctx context.Context, exchange, key string, pub amqp.Publishing) error {
+	return r.publishConfirm(ctx, exchange, key, false, pub)
+}
+
+// PublishMandatory 以确认模式发布 mandatory 消息, 没有队列接收而被退回时返回 ErrPublishUnroutable
+func (r *RabbitMQ) PublishMandatory(ctx context.Context, exchange, key string, pub amqp.Publishing) error {
+	return r.publishConfirm(ctx, exchange, key, true, pub)
+}
+
+func (r *RabbitMQ) publishConfirm(ctx context.Context, exchange, key string, mandatory bool, pub amqp.Publishing) error {
+	injectTrace(ctx, &pub)
+	if err := compressPublishing(&pub, r.cnf.Compression, r.cnf.CompressThreshold); err != nil {
+		return err
+	}
+	if err := r.waitUnblocked(ctx); err != nil {
+		return err
+	}
+
+	r.confirmLock.Lock()
+	defer r.confirmLock.Unlock()
+
+	ch, err := r.getConfirmChannel()
 	if err != nil {
-		fmt.Println("rabbitmq publish json marshal error:", err)
 		return err
 	}
-	pub := amqp.Publishing{
-		ContentType:  "text/json",
-		Body:         body,
-		DeliveryMode: amqp.Persistent,
+
+	if mandatory {
+		// 丢弃之前超时未等到确认的消息的退回
+		r.drainReturns(func(amqp.Return) {})
+	}
+
+	dc, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, mandatory, false, pub)
+	if err != nil {
+		return err
+	}
+
+	ok, err := dc.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPublishNacked
+	}
+
+	if mandatory {
+		// broker 在确认之前发送 basic.return, 确认后退回已在缓冲中
+		r.drainReturns(func(ret amqp.Return) {
+			if err == nil && ret.Exchange == exchange && ret.RoutingKey == key && ret.MessageId == pub.MessageId {
+				err = fmt.Errorf("%w: %d %s", ErrPublishUnroutable, ret.ReplyCode, ret.ReplyText)
+			}
+		})
+	}
+	return err
+}
+
+// drainReturns 读取缓冲中所有被退回的消息, 需持有 confirmLock
+func (r *RabbitMQ) drainReturns(fn func(amqp.Return)) {
+	for {
+		select {
+		case ret, ok := <-r.returns:
+			if !ok {
+				return
+			}
+			fn(ret)
+		default:
+			return
+		}
 	}
-	return r.defChan.PublishWithContext(context.TODO(), exchange, key, false, false, pub)
+}
+
+func (r *RabbitMQ) getConfirmChannel() (*amqp.Channel, error) {
+	if r.confirmChan != nil && !r.confirmChan.IsClosed() {
+		return r.confirmChan, nil
+	}
+
+	ch, err := r.GetChannel()
+	if err != nil {
+		return nil, err
+	}
+	err = ch.Confirm(false)
+	if err != nil {
+		fmt.Println("设置channel的确认模式失败")
+		_ = ch.Close()
+		return nil, err
+	}
+	r.confirmChan = ch
+	r.returns = ch.NotifyReturn(make(chan amqp.Return, returnBufferSize))
+	return ch, nil
 }
 
 type CallbackPubMsg struct {
@@ -148,6 +326,9 @@ func (r *RabbitMQ) SyncCallBackMsg(toExchange, toKey, msg string, useDefExchange
 	select {
 	case msg := <-msgChan:
 		_ = msg.Ack(false)
+		if err = r.decompress(&msg); err != nil {
+			return nil, err
+		}
 		return msg.Body, nil
 	case <-c:
 		return nil, errors.New("mq callback timeout")
@@ -163,14 +344,28 @@ func (r *RabbitMQ) GetMsg(queue string) ([]byte, error) {
 	if !ok {
 		return nil, errors.New("ch get error")
 	}
-	defer func() {
-		_ = r.defChan.Ack(msg.DeliveryTag, false)
-	}()
+	// 无法解压的消息被拒绝, 配置了死信时进入死信队列
+	if err = r.decompress(&msg); err != nil {
+		_ = msg.Reject(false)
+		return nil, err
+	}
+	if err = msg.Ack(false); err != nil {
+		return nil, err
+	}
 	return msg.Body, nil
 }
 
 // Consume 消费信息
 func (r *RabbitMQ) Consume(queue string, fn ConsumeCallBackFunc) error {
+	return r.ConsumeDelivery(queue, func(_ context.Context, d amqp.Delivery) error {
+		return fn(d.Body)
+	})
+}
+
+// ConsumeDelivery 消费信息, 回调返回nil时ack
+// 回调的 ctx 带有消息头中的请求ID等关联信息
+// 压缩的消息解压后再回调, 解压失败的消息被拒绝且不重新入队
+func (r *RabbitMQ) ConsumeDelivery(queue string, fn DeliveryCallBackFunc) error {
 	msgChan, err := r.defChan.Consume(queue, "", false, false, false, false, nil)
 	if err != nil {
 		fmt.Println("channel consume failed: ", err)
@@ -181,11 +376,17 @@ func (r *RabbitMQ) Consume(queue string, fn ConsumeCallBackFunc) error {
 		case msg, ok := <-msgChan:
 			if ok {
 				//log.Printf("[x] %s, %s \n", d.RoutingKey, d.Body)
-				err = fn(msg.Body)
+				if err = r.decompress(&msg); err != nil {
+					fmt.Println("decompress message error:", err)
+					_ = msg.Reject(false)
+					continue
+				}
+				err = fn(extractTrace(r.cxt, msg), msg)
 				if err == nil {
 					_ = msg.Ack(false)
 				} else {
 					fmt.Println("callback function run error:", err)
+					settleFailed(msg, err)
 				}
 
 			} else {
@@ -229,6 +430,11 @@ func (r *RabbitMQ) declareExchange(exchange, kind string) error {
 
 // DeclareBindQueue 声明和绑定队列
 func (r *RabbitMQ) DeclareBindQueue(queue, exchange, key string) error {
+	return r.DeclareBindQueueWithArgs(queue, exchange, key, nil)
+}
+
+// DeclareBindQueueWithArgs 声明和绑定队列, args 为队列参数, 如 x-message-ttl、x-dead-letter-exchange
+func (r *RabbitMQ) DeclareBindQueueWithArgs(queue, exchange, key string, args amqp.Table) error {
 	if queue == "" {
 		return errors.New("queue or ch or exchange is null")
 	}
@@ -239,7 +445,7 @@ func (r *RabbitMQ) DeclareBindQueue(queue, exchange, key string) error {
 		false,
 		false,
 		false,
-		nil,
+		args,
 	)
 
 	if err != nil {
@@ -323,28 +529,16 @@ func (r *RabbitMQ) ReConnect() bool {
 		if i >= ReconnectTry {
 			return false
 		}
-		conn, err := amqp.Dial(r.uri)
-		if err == nil {
-			ch, err := r.conn.Channel()
-			if err != nil {
-				fmt.Println("获取channel失败")
-				time.Sleep(1 * time.Second)
-				continue
-			}
-			err = ch.Qos(r.prefetch, 0, false)
-			if err != nil {
-				fmt.Println("设置channel的Qos失败")
-				time.Sleep(1 * time.Second)
-				continue
-			}
-			r.conn = conn
-			r.defChan = ch
-			break
-		} else {
-			fmt.Println("连接rabbitmq失败")
+		conn, ch, err := r.connect()
+		if err != nil {
+			fmt.Println(err)
 			time.Sleep(1 * time.Second)
 			continue
 		}
+		r.conn = conn
+		r.defChan = ch
+		r.watchFlow(conn, ch)
+		break
 	}
 
 	return true
@@ -357,6 +551,11 @@ func (r *RabbitMQ) IsConnect() bool {
 
 func (r *RabbitMQ) Close() {
 	r.cancel()
+	r.confirmLock.Lock()
+	if r.confirmChan != nil {
+		_ = r.confirmChan.Close()
+	}
+	r.confirmLock.Unlock()
 	_ = r.defChan.Close()
 	_ = r.conn.Close()
 }
@@ -15,7 +15,7 @@ var (
 	qDeviceData = "protocol.v1.report.data"
 )
 
-func getMQ() *RabbitMQ {
+func getMQ(t *testing.T) *RabbitMQ {
 	cnf := &RabbitMQConfig{
 		Host:          "127.0.0.1",
 		Port:          "5672",
@@ -25,14 +25,19 @@ func getMQ() *RabbitMQ {
 		PrefetchCount: 5,
 	}
 
-	return NewRabbitMQ(cnf)
+	mq, err := NewRabbitMQ(cnf)
+	if err != nil {
+		t.Skip("rabbitmq is not available:", err)
+	}
+
+	return mq
 }
 
 func TestRabbitMQ(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode")
 	}
-	rbmq := getMQ()
+	rbmq := getMQ(t)
 
 	err := rbmq.DeclareFanoutEx(eReportData)
 	if err != nil {
@@ -77,7 +82,7 @@ func TestBindKey(t *testing.T) {
 	//if testing.Short() {
 	//	t.Skip("skipping test in short mode")
 	//}
-	rbmq := getMQ()
+	rbmq := getMQ(t)
 
 	err := rbmq.DeclareTopicEx(eReportData)
 	if err != nil {
@@ -115,7 +120,7 @@ func TestBindKey(t *testing.T) {
 }
 
 func TestRabbitMQ_Get(t *testing.T) {
-	mq := getMQ()
+	mq := getMQ(t)
 	defer mq.Close()
 	//ch, err := mq.GetChannel()
 	//if err != nil {
@@ -150,7 +155,7 @@ func TestRabbitMQ_Get(t *testing.T) {
 }
 
 func TestRabbitMQ_DirectExchange(t *testing.T) {
-	mq := getMQ()
+	mq := getMQ(t)
 	defer mq.Close()
 	//ch, err := mq.GetChannel()
 	//if err != nil {
@@ -183,7 +188,7 @@ func TestRabbitMQ_DirectExchange(t *testing.T) {
 
 func TestRabbitMQ_Consume(t *testing.T) {
 	t.Parallel()
-	mq := getMQ()
+	mq := getMQ(t)
 	//defer mq.Close()
 	//ch, err := mq.GetChannel()
 	//if err != nil {
@@ -224,7 +229,7 @@ func TestRabbitMQ_Consume(t *testing.T) {
 func TestRabbitMQ_SyncCallBackMsg(t *testing.T) {
 
 	t.Parallel()
-	mq := getMQ()
+	mq := getMQ(t)
 	defer mq.Close()
 	//ch, err := mq.GetChannel()
 	//if err != nil {
@@ -255,7 +260,7 @@ func TestRabbitMQ_DeclareTmpQueue(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode")
 	}
-	mq := getMQ()
+	mq := getMQ(t)
 	defer mq.Close()
 	ch, _ := mq.GetChannel()
 	defer ch.Close()
@@ -300,7 +305,7 @@ func TestRabbitMQ_Publish(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode")
 	}
-	mq := getMQ()
+	mq := getMQ(t)
 	defer mq.Close()
 
 	err := mq.Publish("test", "", `{"name":"jack", "age":8}`)
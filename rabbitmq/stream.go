@@ -0,0 +1,222 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qumogu/go-tools/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	defaultStreamPrefetch   = 100
+	defaultCheckpointEvery  = 100
+	streamOffsetHeader      = "x-stream-offset"
+	streamCheckpointTimeout = 10 * time.Second
+)
+
+// StreamOffset 流队列开始消费的位置.
+type StreamOffset struct {
+	value interface{}
+}
+
+var (
+	StreamOffsetFirst = StreamOffset{value: "first"} // 从流中保留的第一条消息开始
+	StreamOffsetLast  = StreamOffset{value: "last"}  // 从最后一个数据块开始
+	StreamOffsetNext  = StreamOffset{value: "next"}  // 只消费新消息, 默认值
+)
+
+// StreamOffsetAt 从 t 时刻之后写入的消息开始, 精度为数据块.
+func StreamOffsetAt(t time.Time) StreamOffset {
+	return StreamOffset{value: t}
+}
+
+// StreamOffsetValue 从指定偏移量开始.
+func StreamOffsetValue(offset int64) StreamOffset {
+	return StreamOffset{value: offset}
+}
+
+func (o StreamOffset) arg() interface{} {
+	if o.value == nil {
+		return StreamOffsetNext.value
+	}
+	return o.value
+}
+
+// OffsetStore 保存流队列消费者的偏移量, 消费者重启后从保存的位置继续消费.
+// mongostore.OffsetStore 为基于 mongo 的实现.
+type OffsetStore interface {
+	// LoadOffset 返回已处理的最后一条消息的偏移量, 没有记录时 ok 为 false.
+	LoadOffset(ctx context.Context, consumer, queue string) (offset int64, ok bool, err error)
+	// SaveOffset 保存已处理的最后一条消息的偏移量.
+	SaveOffset(ctx context.Context, consumer, queue string, offset int64) error
+}
+
+// StreamConsumeOptions 流队列消费参数, 零值使用默认值.
+type StreamConsumeOptions struct {
+	Name            string       // 消费者名称, 作为偏移量记录的key, 使用 Store 时必填
+	Offset          StreamOffset // 没有偏移量记录时开始消费的位置, 默认 StreamOffsetNext
+	Store           OffsetStore  // 偏移量存储, 为空时不保存偏移量
+	CheckpointEvery int          // 每处理多少条消息保存一次偏移量, 默认100
+	Prefetch        int          // 流队列必须设置 prefetch, 默认100
+}
+
+// DeclareStreamQueue 声明流队列并绑定到 exchange, args 可设置 x-max-age、x-max-length-bytes 等参数.
+func (r *RabbitMQ) DeclareStreamQueue(queue, exchange, key string, args amqp.Table) error {
+	return r.DeclareBindQueueWithArgs(queue, exchange, key, streamQueueArgs(args))
+}
+
+func streamQueueArgs(args amqp.Table) amqp.Table {
+	streamArgs := amqp.Table{"x-queue-type": "stream"}
+	for k, v := range args {
+		streamArgs[k] = v
+	}
+	return streamArgs
+}
+
+// deliveryStreamOffset 返回流队列消息的偏移量.
+func deliveryStreamOffset(d amqp.Delivery) (int64, bool) {
+	offset, err := tableInt(d.Headers[streamOffsetHeader])
+	if err != nil {
+		return 0, false
+	}
+	return offset, true
+}
+
+// startOffset 有偏移量记录时从记录的下一条开始, 否则使用 opts.Offset.
+func (opts StreamConsumeOptions) startOffset(ctx context.Context, queue string) (StreamOffset, error) {
+	if opts.Store == nil {
+		return opts.Offset, nil
+	}
+	offset, ok, err := opts.Store.LoadOffset(ctx, opts.Name, queue)
+	if err != nil {
+		return StreamOffset{}, err
+	}
+	if !ok {
+		return opts.Offset, nil
+	}
+	return StreamOffsetValue(offset + 1), nil
+}
+
+// ConsumeStream 从流队列消费消息, 使用独立的通道.
+// 回调返回错误时保存之前已处理的偏移量并返回错误, 重启后从失败的消息重新消费.
+// 无法解压的消息记录日志后跳过, 与队列消费者拒绝进入死信队列的处理一致.
+// 偏移量按 CheckpointEvery 批量保存, 消费者异常退出时可能重复消费最多 CheckpointEvery 条消息.
+func (r *RabbitMQ) ConsumeStream(queue string, opts StreamConsumeOptions, fn DeliveryCallBackFunc) error {
+	if opts.Store != nil && opts.Name == "" {
+		return errors.New("stream consumer name is required when using offset store")
+	}
+	if opts.CheckpointEvery <= 0 {
+		opts.CheckpointEvery = defaultCheckpointEvery
+	}
+	if opts.Prefetch <= 0 {
+		opts.Prefetch = defaultStreamPrefetch
+	}
+
+	start, err := opts.startOffset(r.cxt, queue)
+	if err != nil {
+		return err
+	}
+
+	ch, err := r.GetChannel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if err = ch.Qos(opts.Prefetch, 0, false); err != nil {
+		return err
+	}
+
+	args := amqp.Table{streamOffsetHeader: start.arg()}
+	msgChan, err := ch.Consume(queue, opts.Name, false, false, false, false, args)
+	if err != nil {
+		fmt.Println("channel consume failed: ", err)
+		return err
+	}
+
+	var (
+		last    int64
+		pending int // 上次保存后处理的消息数
+	)
+	checkpoint := func() error {
+		if opts.Store == nil || pending == 0 {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), streamCheckpointTimeout)
+		defer cancel()
+		if err := opts.Store.SaveOffset(ctx, opts.Name, queue, last); err != nil {
+			return err
+		}
+		pending = 0
+		return nil
+	}
+
+	for {
+		select {
+		case msg, ok := <-msgChan:
+			if !ok {
+				fmt.Println("mq channel is closed")
+				if err = checkpoint(); err != nil {
+					fmt.Println("save stream offset error:", err)
+				}
+				return amqp.ErrClosed
+			}
+			ctx := extractTrace(r.cxt, msg)
+			if err = r.decompress(&msg); err != nil {
+				// 重试也无法解压, 跳过该消息, 否则重启后总是停在这条消息
+				logger.WithContext(ctx).Errorw("stream skip message that cannot be decompressed",
+					"queue", queue, "message_id", msg.MessageId, "error", err)
+			} else if err = fn(ctx, msg); err != nil {
+				fmt.Println("stream callback function run error:", err)
+				if cerr := checkpoint(); cerr != nil {
+					fmt.Println("save stream offset error:", cerr)
+				}
+				return err
+			}
+			_ = msg.Ack(false)
+
+			if offset, ok := deliveryStreamOffset(msg); ok {
+				last = offset
+				pending++
+			}
+			if pending >= opts.CheckpointEvery {
+				if err = checkpoint(); err != nil {
+					fmt.Println("save stream offset error:", err)
+				}
+			}
+
+		case <-r.cxt.Done():
+			fmt.Println("service is closing")
+			if err = checkpoint(); err != nil {
+				fmt.Println("save stream offset error:", err)
+			}
+			return nil
+		}
+	}
+}
+
+func tableInt(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	}
+
+	return 0, fmt.Errorf("unsupported type %T", v)
+}
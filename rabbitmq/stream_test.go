@@ -0,0 +1,65 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type memOffsetStore map[string]int64
+
+func (s memOffsetStore) LoadOffset(_ context.Context, consumer, queue string) (int64, bool, error) {
+	offset, ok := s[consumer+"/"+queue]
+	return offset, ok, nil
+}
+
+func (s memOffsetStore) SaveOffset(_ context.Context, consumer, queue string, offset int64) error {
+	s[consumer+"/"+queue] = offset
+	return nil
+}
+
+func TestStreamQueueArgs(t *testing.T) {
+	args := streamQueueArgs(amqp.Table{"x-max-age": "7D"})
+	if args["x-queue-type"] != "stream" || args["x-max-age"] != "7D" {
+		t.Errorf("unexpected stream args: %v", args)
+	}
+}
+
+func TestStreamConsumeOptions_StartOffset(t *testing.T) {
+	at := time.Now()
+	tests := []struct {
+		name  string
+		opts  StreamConsumeOptions
+		store memOffsetStore
+		want  interface{}
+	}{
+		{"default", StreamConsumeOptions{}, nil, "next"},
+		{"first", StreamConsumeOptions{Offset: StreamOffsetFirst}, nil, "first"},
+		{"timestamp", StreamConsumeOptions{Offset: StreamOffsetAt(at)}, nil, at},
+		{"no checkpoint", StreamConsumeOptions{Name: "c1", Offset: StreamOffsetValue(5)}, memOffsetStore{}, int64(5)},
+		{"resume", StreamConsumeOptions{Name: "c1", Offset: StreamOffsetFirst}, memOffsetStore{"c1/device.data": 41}, int64(42)},
+	}
+	for _, tt := range tests {
+		if tt.store != nil {
+			tt.opts.Store = tt.store
+		}
+		got, err := tt.opts.startOffset(context.Background(), "device.data")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.arg() != tt.want {
+			t.Errorf("%s: start offset = %v, want %v", tt.name, got.arg(), tt.want)
+		}
+	}
+}
+
+func TestDeliveryStreamOffset(t *testing.T) {
+	if offset, ok := deliveryStreamOffset(amqp.Delivery{Headers: amqp.Table{"x-stream-offset": int64(7)}}); !ok || offset != 7 {
+		t.Errorf("offset = %d, %v", offset, ok)
+	}
+	if _, ok := deliveryStreamOffset(amqp.Delivery{}); ok {
+		t.Error("delivery without offset header should not have offset")
+	}
+}
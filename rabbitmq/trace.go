@@ -0,0 +1,38 @@
+package rabbitmq
+
+import (
+	"context"
+
+	"github.com/qumogu/go-tools/trace"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// injectTrace 将 ctx 中的请求ID等关联信息写入消息头, 已存在的消息头不覆盖
+func injectTrace(ctx context.Context, pub *amqp.Publishing) {
+	md := trace.FromContext(ctx)
+	if len(md) == 0 {
+		return
+	}
+
+	headers := make(amqp.Table, len(pub.Headers)+len(md))
+	for k, v := range pub.Headers {
+		headers[k] = v
+	}
+	for k, v := range md {
+		if _, ok := headers[k]; !ok {
+			headers[k] = v
+		}
+	}
+	pub.Headers = headers
+}
+
+// extractTrace 将消息头中的关联信息写入 ctx, 回调中可用 logger.WithContext(ctx) 记录日志
+func extractTrace(ctx context.Context, d amqp.Delivery) context.Context {
+	md := trace.Metadata{}
+	for _, k := range trace.Keys {
+		if v, ok := d.Headers[k].(string); ok {
+			md[k] = v
+		}
+	}
+	return trace.NewContext(ctx, md)
+}
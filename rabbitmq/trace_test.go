@@ -0,0 +1,47 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qumogu/go-tools/trace"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestMemoryBroker_TracePropagation(t *testing.T) {
+	mq := NewMemoryBroker()
+	defer mq.Close()
+
+	_ = mq.DeclareDirectEx("test.trace")
+	_ = mq.DeclareBindQueue("test.trace.q", "test.trace", "job")
+
+	ctx := trace.WithRequestID(context.Background(), "req-1")
+	if err := mq.PublishWithContext(ctx, "test.trace", "job", "payload"); err != nil {
+		t.Fatal(err)
+	}
+	// 已设置的消息头不被覆盖
+	pub := amqp.Publishing{Headers: amqp.Table{trace.HeaderRequestID: "req-origin"}, Body: []byte(`"x"`)}
+	if err := mq.PublishConfirm(ctx, "test.trace", "job", pub); err != nil {
+		t.Fatal(err)
+	}
+	if pub.Headers[trace.HeaderRequestID] != "req-origin" {
+		t.Fatal("caller's headers should not be modified")
+	}
+
+	consumeCtx, cancel := context.WithCancel(context.Background())
+	var got []string
+	go func() {
+		_ = mq.ConsumeDelivery("test.trace.q", func(ctx context.Context, d amqp.Delivery) error {
+			got = append(got, trace.RequestID(ctx))
+			if len(got) == 2 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	<-consumeCtx.Done()
+
+	if got[0] != "req-1" || got[1] != "req-origin" {
+		t.Errorf("request ids = %v", got)
+	}
+}
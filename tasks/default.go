@@ -0,0 +1,73 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/qumogu/go-tools/rabbitmq"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	DefaultManager *Manager
+	initOnce       sync.Once
+	initErr        error
+
+	// defaultHandlers 包级 Register 注册的处理函数, Init 前后注册均可
+	defaultHandlers = newRegistry()
+
+	ErrNotInitialized = errors.New("tasks: default manager is not initialized")
+)
+
+// Init 初始化默认的任务系统, db 为 nil 时返回 ErrNoDatabase, 只有第一次调用生效.
+func Init(broker rabbitmq.Broker, db *mongo.Database, conf Config) error {
+	initOnce.Do(func() {
+		if db == nil {
+			initErr = ErrNoDatabase
+
+			return
+		}
+
+		DefaultManager = newManager(broker, db, conf, defaultHandlers)
+	})
+
+	return initErr
+}
+
+// Register 在默认任务系统中注册任务处理函数.
+func Register(name string, fn HandlerFunc) error {
+	return defaultHandlers.register(name, fn, Options{})
+}
+
+// RegisterWithOptions 在默认任务系统中注册任务处理函数并指定重试次数与超时时间.
+func RegisterWithOptions(name string, fn HandlerFunc, opts Options) error {
+	return defaultHandlers.register(name, fn, opts)
+}
+
+// Enqueue 使用默认任务系统创建任务.
+func Enqueue(ctx context.Context, name string, args interface{}) (string, error) {
+	if DefaultManager == nil {
+		return "", ErrNotInitialized
+	}
+
+	return DefaultManager.Enqueue(ctx, name, args)
+}
+
+// Status 返回默认任务系统中任务的状态.
+func Status(ctx context.Context, id string) (*Task, error) {
+	if DefaultManager == nil {
+		return nil, ErrNotInitialized
+	}
+
+	return DefaultManager.Status(ctx, id)
+}
+
+// Run 启动默认任务系统的 worker.
+func Run() error {
+	if DefaultManager == nil {
+		return ErrNotInitialized
+	}
+
+	return DefaultManager.Run()
+}
@@ -0,0 +1,6 @@
+package tasks
+
+const (
+	FailureExit            = -6000
+	ErrTaskNotFoundCodeKey = 6001
+)
@@ -0,0 +1,426 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qumogu/go-tools/logger"
+	"github.com/qumogu/go-tools/mongodb"
+	"github.com/qumogu/go-tools/rabbitmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	DefaultQueue      = "tasks.default"
+	DefaultCollection = "tasks"
+
+	defaultWorkers       = 4
+	defaultMaxRetries    = 3
+	defaultTimeout       = 5 * time.Minute
+	defaultRetryDelay    = 10 * time.Second
+	defaultMaxRetryDelay = 10 * time.Minute
+)
+
+var (
+	ErrTaskNotFound      = errors.New("task not found")
+	ErrTaskNotRegistered = errors.New("task is not registered")
+	ErrTaskTimeout       = errors.New("task timeout")
+	ErrNoDatabase        = errors.New("tasks: database is required")
+)
+
+// HandlerFunc 任务处理函数, args 为 Enqueue 时参数的 json 编码, 返回值以 json 编码保存为任务结果.
+// 超时后 ctx 被取消, 处理函数应在 ctx 结束后尽快返回.
+type HandlerFunc func(ctx context.Context, args json.RawMessage) (interface{}, error)
+
+// Options 任务的执行选项, 零值使用 Config 中的默认值.
+type Options struct {
+	MaxRetries int           // 失败后的最大重试次数, 小于0时不重试
+	Timeout    time.Duration // 单次执行的超时时间
+}
+
+type handler struct {
+	fn   HandlerFunc
+	opts Options
+}
+
+// registry 任务名称到处理函数的映射.
+type registry struct {
+	lock     sync.RWMutex
+	handlers map[string]*handler
+}
+
+func newRegistry() *registry {
+	return &registry{handlers: make(map[string]*handler)}
+}
+
+func (r *registry) register(name string, fn HandlerFunc, opts Options) error {
+	if name == "" || fn == nil {
+		return errors.New("task name or handler is null")
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.handlers[name]; ok {
+		return fmt.Errorf("task %s is already registered", name)
+	}
+
+	r.handlers[name] = &handler{fn: fn, opts: opts}
+
+	return nil
+}
+
+func (r *registry) get(name string) *handler {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.handlers[name]
+}
+
+// Config 任务系统配置, 零值使用默认值.
+type Config struct {
+	Queue         string        // 任务队列, 默认 tasks.default
+	Collection    string        // 保存任务状态的集合, 默认 tasks
+	Workers       int           // 并发执行的任务数, 默认4
+	MaxRetries    int           // 默认最大重试次数, 默认3, 小于0时不重试
+	Timeout       time.Duration // 默认单次执行超时时间, 默认5分钟
+	RetryDelay    time.Duration // 首次重试的延迟, 之后每次翻倍, 默认10s
+	MaxRetryDelay time.Duration // 最大重试延迟, 默认10分钟, 不能超过延迟队列的最大分档
+}
+
+// Manager 基于 rabbitmq 的后台任务系统.
+// Enqueue 将任务状态写入 mongo 并发布任务消息, Run 启动的 worker 执行任务,
+// 失败时通过延迟队列按退避时间重试, 执行状态、结果与错误保存在 mongo 中.
+//
+//	m, err := tasks.NewManager(mq, db, tasks.Config{})
+//	_ = m.Register("export_report", exportReport)
+//	go m.Run()
+//	id, err := m.Enqueue(ctx, "export_report", ReportArgs{Month: "2023-01"})
+type Manager struct {
+	broker   rabbitmq.Broker
+	base     *mongodb.Base
+	conf     Config
+	handlers *registry
+
+	declareLock sync.Mutex
+	declared    bool
+}
+
+// NewManager 创建任务系统, 任务状态保存在 db 中, db 为 nil 时返回 ErrNoDatabase.
+func NewManager(broker rabbitmq.Broker, db *mongo.Database, conf Config) (*Manager, error) {
+	if db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	return newManager(broker, db, conf, newRegistry()), nil
+}
+
+func newManager(broker rabbitmq.Broker, db *mongo.Database, conf Config, handlers *registry) *Manager {
+	if conf.Queue == "" {
+		conf.Queue = DefaultQueue
+	}
+
+	if conf.Collection == "" {
+		conf.Collection = DefaultCollection
+	}
+
+	if conf.Workers <= 0 {
+		conf.Workers = defaultWorkers
+	}
+
+	if conf.MaxRetries == 0 {
+		conf.MaxRetries = defaultMaxRetries
+	}
+
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultTimeout
+	}
+
+	if conf.RetryDelay <= 0 {
+		conf.RetryDelay = defaultRetryDelay
+	}
+
+	if conf.MaxRetryDelay < conf.RetryDelay {
+		conf.MaxRetryDelay = defaultMaxRetryDelay
+	}
+
+	m := &Manager{
+		broker:   broker,
+		conf:     conf,
+		handlers: handlers,
+	}
+
+	if db != nil {
+		m.base = mongodb.NewBase(db.Collection(conf.Collection))
+	}
+
+	return m
+}
+
+// Register 注册任务处理函数, 使用默认的重试次数与超时时间.
+func (m *Manager) Register(name string, fn HandlerFunc) error {
+	return m.handlers.register(name, fn, Options{})
+}
+
+// RegisterWithOptions 注册任务处理函数并指定重试次数与超时时间.
+func (m *Manager) RegisterWithOptions(name string, fn HandlerFunc, opts Options) error {
+	return m.handlers.register(name, fn, opts)
+}
+
+// Declare 声明任务队列.
+func (m *Manager) Declare() error {
+	m.declareLock.Lock()
+	defer m.declareLock.Unlock()
+
+	if m.declared {
+		return nil
+	}
+
+	if err := m.broker.DeclareBindQueue(m.conf.Queue, "", ""); err != nil {
+		return err
+	}
+
+	m.declared = true
+
+	return nil
+}
+
+// Enqueue 创建任务并发布到任务队列, 返回任务ID, args 以 json 编码.
+// 发布失败时任务被标记为失败.
+func (m *Manager) Enqueue(ctx context.Context, name string, args interface{}) (string, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+
+	if err = m.Declare(); err != nil {
+		return "", err
+	}
+
+	id, err := m.base.Create(ctx, &Task{
+		Name:        name,
+		Args:        data,
+		Status:      StatusPending,
+		CreatedTime: time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	msg := taskMessage{ID: id, Name: name}
+
+	err = m.broker.PublishWithOptions(ctx, "", m.conf.Queue, msg, rabbitmq.PublishOptions{MessageId: id})
+	if err != nil {
+		_ = m.base.UpdateByID(ctx, id, bson.M{
+			"status":        StatusFailed,
+			"error":         "publish task: " + err.Error(),
+			"finished_time": time.Now(),
+		})
+
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Status 返回任务的状态与结果, 任务不存在时返回 ErrTaskNotFound.
+func (m *Manager) Status(ctx context.Context, id string) (*Task, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, ErrTaskNotFound
+	}
+
+	task := &Task{}
+
+	err := m.base.FindOneByID(ctx, id, task)
+	if err != nil {
+		if errors.Is(err, mongodb.ErrNotFound) {
+			return nil, ErrTaskNotFound
+		}
+
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// Run 启动 Workers 个 worker 执行任务, 直到 broker 关闭.
+func (m *Manager) Run() error {
+	if err := m.Declare(); err != nil {
+		return err
+	}
+
+	g := errgroup.Group{}
+	for i := 0; i < m.conf.Workers; i++ {
+		g.Go(func() error {
+			return m.broker.ConsumeDelivery(m.conf.Queue, m.handle)
+		})
+	}
+
+	return g.Wait()
+}
+
+// handle 执行一条任务消息, 只有读写 mongo 或发布重试失败时返回错误.
+// 读写 mongo 失败时消息不被确认; 发布重试失败时消息重新入队, 由重新投递的消息再次执行.
+func (m *Manager) handle(ctx context.Context, d amqp.Delivery) error {
+	msg := taskMessage{}
+	if err := json.Unmarshal(d.Body, &msg); err != nil {
+		logger.WithContext(ctx).Errorw("decode task message failed", "err", err)
+
+		return nil
+	}
+
+	log := logger.WithContext(ctx).With("task_id", msg.ID, "task", msg.Name)
+
+	task, err := m.Status(ctx, msg.ID)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			log.Warnw("task not found, drop message")
+
+			return nil
+		}
+
+		return err
+	}
+
+	// 重复投递的消息
+	if task.Done() {
+		return nil
+	}
+
+	h := m.handlers.get(task.Name)
+	if h == nil {
+		return m.finish(ctx, msg.ID, nil, ErrTaskNotRegistered)
+	}
+
+	attempts := task.Attempts + 1
+
+	err = m.base.UpdateByID(ctx, msg.ID, bson.M{
+		"status":       StatusRunning,
+		"attempts":     attempts,
+		"started_time": time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := m.execute(ctx, h, task.Args)
+	if err == nil {
+		return m.finish(ctx, msg.ID, result, nil)
+	}
+
+	log.Warnw("task failed", "attempts", attempts, "err", err)
+
+	if attempts > m.maxRetries(h) {
+		return m.finish(ctx, msg.ID, nil, err)
+	}
+
+	err = m.base.UpdateByID(ctx, msg.ID, bson.M{
+		"status": StatusRetrying,
+		"error":  err.Error(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = m.broker.PublishAfter("", m.conf.Queue, msg, m.backoff(attempts)); err != nil {
+		log.Errorw("publish task retry failed, requeue", "err", err)
+
+		return rabbitmq.Requeue(err)
+	}
+
+	return nil
+}
+
+// execute 在超时时间内执行处理函数, 处理函数 panic 时返回错误.
+func (m *Manager) execute(ctx context.Context, h *handler, args json.RawMessage) (json.RawMessage, error) {
+	timeout := h.opts.Timeout
+	if timeout <= 0 {
+		timeout = m.conf.Timeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type output struct {
+		result interface{}
+		err    error
+	}
+
+	done := make(chan output, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- output{err: fmt.Errorf("task panic: %v", r)}
+			}
+		}()
+
+		result, err := h.fn(ctx, args)
+		done <- output{result: result, err: err}
+	}()
+
+	select {
+	case out := <-done:
+		if out.err != nil {
+			return nil, out.err
+		}
+
+		if out.result == nil {
+			return nil, nil
+		}
+
+		return json.Marshal(out.result)
+	case <-ctx.Done():
+		return nil, ErrTaskTimeout
+	}
+}
+
+// finish 保存任务的最终状态.
+func (m *Manager) finish(ctx context.Context, id string, result json.RawMessage, taskErr error) error {
+	update := bson.M{
+		"status":        StatusSuccess,
+		"error":         "",
+		"finished_time": time.Now(),
+	}
+
+	if result != nil {
+		update["result"] = result
+	}
+
+	if taskErr != nil {
+		update["status"] = StatusFailed
+		update["error"] = taskErr.Error()
+	}
+
+	return m.base.UpdateByID(ctx, id, update)
+}
+
+func (m *Manager) maxRetries(h *handler) int {
+	if h.opts.MaxRetries != 0 {
+		return h.opts.MaxRetries
+	}
+
+	return m.conf.MaxRetries
+}
+
+// backoff 第 attempts 次执行失败后的重试延迟.
+func (m *Manager) backoff(attempts int) time.Duration {
+	delay := m.conf.RetryDelay
+	for i := 1; i < attempts && delay < m.conf.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > m.conf.MaxRetryDelay {
+		delay = m.conf.MaxRetryDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,282 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumogu/go-tools/mongodb"
+	"github.com/qumogu/go-tools/rabbitmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestRegistry(t *testing.T) {
+	r := newRegistry()
+	fn := func(ctx context.Context, args json.RawMessage) (interface{}, error) { return nil, nil }
+
+	if err := r.register("export_report", fn, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.register("export_report", fn, Options{}); err == nil {
+		t.Error("duplicate registration should fail")
+	}
+	if r.get("export_report") == nil || r.get("unknown") != nil {
+		t.Error("unexpected registry lookup")
+	}
+}
+
+func TestManager_Execute(t *testing.T) {
+	m := newManager(nil, nil, Config{Timeout: 50 * time.Millisecond}, newRegistry())
+
+	sum := &handler{fn: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+		var nums []int
+		if err := json.Unmarshal(args, &nums); err != nil {
+			return nil, err
+		}
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return map[string]int{"total": total}, nil
+	}}
+	result, err := m.execute(context.Background(), sum, json.RawMessage(`[1,2,3]`))
+	if err != nil || string(result) != `{"total":6}` {
+		t.Errorf("result = %s, err = %v", result, err)
+	}
+
+	slow := &handler{fn: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	if _, err = m.execute(context.Background(), slow, nil); !errors.Is(err, ErrTaskTimeout) {
+		t.Errorf("want ErrTaskTimeout, got %v", err)
+	}
+
+	panics := &handler{fn: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+		panic("boom")
+	}}
+	if _, err = m.execute(context.Background(), panics, nil); err == nil {
+		t.Error("panic should be returned as error")
+	}
+}
+
+func TestManager_Backoff(t *testing.T) {
+	m := newManager(nil, nil, Config{RetryDelay: time.Second, MaxRetryDelay: 5 * time.Second}, newRegistry())
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := m.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+// memCollection 内存中的任务集合, 只实现 Manager 用到的方法.
+type memCollection struct {
+	mongodb.Collection
+
+	mu   sync.Mutex
+	docs map[primitive.ObjectID]bson.M
+}
+
+func newMemCollection() *memCollection {
+	return &memCollection{docs: make(map[primitive.ObjectID]bson.M)}
+}
+
+func toM(v interface{}) bson.M {
+	data, _ := bson.Marshal(v)
+	m := bson.M{}
+	_ = bson.Unmarshal(data, &m)
+
+	return m
+}
+
+func (c *memCollection) InsertOne(_ context.Context, document interface{},
+	_ ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	doc := toM(document)
+	id := primitive.NewObjectID()
+	doc["_id"] = id
+	c.docs[id] = doc
+
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+func (c *memCollection) UpdateByID(ctx context.Context, id interface{}, update interface{},
+	opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.UpdateOne(ctx, bson.M{"_id": id}, update, opts...)
+}
+
+func (c *memCollection) UpdateOne(_ context.Context, filter interface{}, update interface{},
+	_ ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	doc, ok := c.docs[toM(filter)["_id"].(primitive.ObjectID)]
+	if !ok {
+		return &mongo.UpdateResult{}, nil
+	}
+
+	for k, v := range toM(toM(update)["$set"]) {
+		doc[k] = v
+	}
+
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func (c *memCollection) FindOne(_ context.Context, filter interface{},
+	_ ...*options.FindOneOptions) *mongo.SingleResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	doc, ok := c.docs[toM(filter)["_id"].(primitive.ObjectID)]
+	if !ok {
+		return mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+	}
+
+	return mongo.NewSingleResultFromDocument(doc, nil, nil)
+}
+
+// failRetryBroker 发布重试消息失败.
+type failRetryBroker struct {
+	rabbitmq.Broker
+}
+
+func (b failRetryBroker) PublishAfter(exchange, key string, msg interface{}, delay time.Duration) error {
+	return errors.New("broker unavailable")
+}
+
+func newTestManager(broker rabbitmq.Broker) *Manager {
+	m := newManager(broker, nil, Config{MaxRetries: 1, RetryDelay: time.Second}, newRegistry())
+	m.base = mongodb.NewBase(newMemCollection())
+
+	return m
+}
+
+// enqueue 创建任务并取出任务消息.
+func enqueue(t *testing.T, m *Manager, mq *rabbitmq.MemoryBroker, name string) (string, amqp.Delivery) {
+	id, err := m.Enqueue(context.Background(), name, []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := mq.GetMsg(DefaultQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id, amqp.Delivery{MessageId: id, Body: body}
+}
+
+func TestManager_Handle(t *testing.T) {
+	mq := rabbitmq.NewMemoryBroker()
+	defer mq.Close()
+
+	m := newTestManager(mq)
+
+	fail := true
+	_ = m.Register("sum", func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+		if fail {
+			return nil, errors.New("db busy")
+		}
+
+		return 6, nil
+	})
+
+	id, d := enqueue(t, m, mq, "sum")
+
+	// 第一次失败, 等待重试
+	if err := m.handle(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	task, _ := m.Status(context.Background(), id)
+	if task.Status != StatusRetrying || task.Attempts != 1 || task.Error != "db busy" {
+		t.Fatalf("task should be retrying, got %+v", task)
+	}
+
+	// 重试成功
+	fail = false
+	if err := m.handle(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	task, _ = m.Status(context.Background(), id)
+	if task.Status != StatusSuccess || task.Attempts != 2 || string(task.Result) != "6" {
+		t.Fatalf("task should succeed, got %+v", task)
+	}
+
+	// 重复投递的消息不再执行
+	fail = true
+	if err := m.handle(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ = m.Status(context.Background(), id); task.Status != StatusSuccess {
+		t.Errorf("finished task should not run again, got %+v", task)
+	}
+}
+
+func TestManager_HandleFinalFailure(t *testing.T) {
+	mq := rabbitmq.NewMemoryBroker()
+	defer mq.Close()
+
+	m := newTestManager(mq)
+	_ = m.Register("sum", func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+		return nil, errors.New("bad args")
+	})
+
+	id, d := enqueue(t, m, mq, "sum")
+
+	for i := 0; i < 2; i++ {
+		if err := m.handle(context.Background(), d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	task, _ := m.Status(context.Background(), id)
+	if task.Status != StatusFailed || task.Attempts != 2 || task.Error != "bad args" {
+		t.Errorf("task should fail after retries, got %+v", task)
+	}
+}
+
+func TestManager_HandleRetryPublishFailed(t *testing.T) {
+	mq := rabbitmq.NewMemoryBroker()
+	defer mq.Close()
+
+	m := newTestManager(failRetryBroker{Broker: mq})
+
+	var calls int32
+	_ = m.Register("sum", func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+
+		return nil, errors.New("db busy")
+	})
+
+	if _, err := m.Enqueue(context.Background(), "sum", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = mq.ConsumeDelivery(DefaultQueue, m.handle) }()
+
+	// 发布重试失败的消息重新入队, 再次执行后超过重试次数
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&calls) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("task should be requeued and run again, calls = %d", n)
+	}
+}
+
+func TestNewManager_NoDatabase(t *testing.T) {
+	if _, err := NewManager(nil, nil, Config{}); !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("want ErrNoDatabase, got %v", err)
+	}
+}
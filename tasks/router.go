@@ -0,0 +1,40 @@
+package tasks
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qumogu/go-tools/httpserver"
+)
+
+type taskController struct {
+	m *Manager
+}
+
+// StatusRoutes 注册任务状态查询接口.
+// It defines
+//
+//	GET: /tasks/:id
+func StatusRoutes(group *gin.RouterGroup, m *Manager) {
+	ctl := &taskController{m: m}
+
+	group.GET("/tasks/:id", ctl.StatusController)
+}
+
+func (ctl *taskController) StatusController(c *gin.Context) {
+	task, err := ctl.m.Status(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			httpserver.FailureWithHTTPStatus(c, http.StatusNotFound, ErrTaskNotFoundCodeKey, err.Error())
+
+			return
+		}
+
+		httpserver.Failure(c, FailureExit, err.Error())
+
+		return
+	}
+
+	httpserver.Success(c, task)
+}
@@ -0,0 +1,41 @@
+package tasks
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	StatusPending  = "pending"  // 已入队, 等待执行
+	StatusRunning  = "running"  // 执行中
+	StatusRetrying = "retrying" // 执行失败, 等待重试
+	StatusSuccess  = "success"  // 执行成功
+	StatusFailed   = "failed"   // 重试次数用完或无法执行
+)
+
+// Task 任务的状态与结果, 保存在 mongo 中.
+type Task struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`
+	Args         json.RawMessage    `bson:"args" json:"args"`
+	Status       string             `bson:"status" json:"status"`
+	Attempts     int                `bson:"attempts" json:"attempts"`                 // 已执行次数
+	Result       json.RawMessage    `bson:"result,omitempty" json:"result,omitempty"` // 执行成功的返回值
+	Error        string             `bson:"error,omitempty" json:"error,omitempty"`   // 最近一次失败原因
+	CreatedTime  time.Time          `bson:"created_time" json:"created_time"`
+	StartedTime  time.Time          `bson:"started_time,omitempty" json:"started_time"`   // 最近一次开始执行时间
+	FinishedTime time.Time          `bson:"finished_time,omitempty" json:"finished_time"` // 成功或最终失败的时间
+}
+
+// Done 任务是否已结束.
+func (t *Task) Done() bool {
+	return t.Status == StatusSuccess || t.Status == StatusFailed
+}
+
+// taskMessage 发布到 rabbitmq 的任务消息, 参数保存在 mongo 中.
+type taskMessage struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,84 @@
+package trace
+
+import (
+	"context"
+	"sort"
+)
+
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceparent = "traceparent" // W3C Trace Context
+)
+
+// Keys 在 HTTP 请求头与消息头之间传递的元数据key.
+var Keys = []string{HeaderRequestID, HeaderTraceparent}
+
+// logFieldNames 元数据在日志中的字段名, 未列出的key直接作为字段名.
+var logFieldNames = map[string]string{
+	HeaderRequestID:   "request_id",
+	HeaderTraceparent: "traceparent",
+}
+
+// Metadata 随请求与消息传递的关联信息, 如请求ID.
+type Metadata map[string]string
+
+type metadataKey struct{}
+
+// NewContext 将 md 合并到 ctx 中已有的元数据, 同名key以 md 为准.
+func NewContext(ctx context.Context, md Metadata) context.Context {
+	if len(md) == 0 {
+		return ctx
+	}
+
+	merged := Metadata{}
+	for k, v := range FromContext(ctx) {
+		merged[k] = v
+	}
+
+	for k, v := range md {
+		if v != "" {
+			merged[k] = v
+		}
+	}
+
+	return context.WithValue(ctx, metadataKey{}, merged)
+}
+
+// FromContext 返回 ctx 中的元数据, 返回值不可修改.
+func FromContext(ctx context.Context) Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+
+	return md
+}
+
+// WithRequestID 在 ctx 中设置请求ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return NewContext(ctx, Metadata{HeaderRequestID: id})
+}
+
+// RequestID 返回 ctx 中的请求ID.
+func RequestID(ctx context.Context) string {
+	return FromContext(ctx)[HeaderRequestID]
+}
+
+// LogFields 返回用于 logger.With 的键值对, 按key排序.
+func (md Metadata) LogFields() []interface{} {
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	fields := make([]interface{}, 0, len(keys)*2)
+	for _, k := range keys {
+		name, ok := logFieldNames[k]
+		if !ok {
+			name = k
+		}
+
+		fields = append(fields, name, md[k])
+	}
+
+	return fields
+}